@@ -35,18 +35,50 @@ type FileExplorerState struct {
 }
 
 type EnvConfig struct {
-	Global       map[string]string            `json:"global"`
-	Environments map[string]map[string]string `json:"environments"`
+	// Version is the format version of the file, see migrateEnvConfig.
+	Version      int                            `json:"version,omitempty"`
+	Global       map[string]EnvValue            `json:"global"`
+	Environments map[string]map[string]EnvValue `json:"environments"`
+	// Extends maps an environment to the environment it inherits from.
+	Extends map[string]string `json:"extends,omitempty"`
+	// OAuth2 maps an environment to the token endpoint it gets its bearer token from.
+	OAuth2 map[string]OAuth2Config `json:"oauth2,omitempty"`
 }
 
 type ReturnValue struct {
-	FileContent  string            `json:"fileContent,omitempty"`
-	FileExplorer FileExplorerState `json:"fileExplorer"`
-	Files        []FileInfo        `json:"files"`
-	Error        string            `json:"error,omitempty"`
-	HurlReport   HurlReport        `json:"hurlReport,omitempty"`
-	Envs         []string          `json:"envs,omitempty"`
-	EnvFilePath  string            `json:"envFilePath,omitempty"`
+	FileContent  string               `json:"fileContent,omitempty"`
+	FileExplorer FileExplorerState    `json:"fileExplorer"`
+	Files        []FileInfo           `json:"files"`
+	Error        string               `json:"error,omitempty"`
+	HurlReport   HurlReport           `json:"hurlReport,omitempty"`
+	Envs         []string             `json:"envs,omitempty"`
+	EnvFilePath  string               `json:"envFilePath,omitempty"`
+	Outline      []OutlineEntry       `json:"outline,omitempty"`
+	Variables    *VariableAnalysis    `json:"variables,omitempty"`
+	Completions  *CompletionList      `json:"completions,omitempty"`
+	Rename       *RenamePreview       `json:"rename,omitempty"`
+	Templates    []FileTemplate       `json:"templates,omitempty"`
+	EnvConfig    *EnvConfig           `json:"envConfig,omitempty"`
+	Secrets      *SecretsInfo         `json:"secrets,omitempty"`
+	SecretValue  string               `json:"secretValue,omitempty"`
+	Redaction    *RedactionConfig     `json:"redaction,omitempty"`
+	EnvLayers    []EnvLayerInfo       `json:"envLayers,omitempty"`
+	Resolved     []ResolvedVariable   `json:"resolved,omitempty"`
+	DotenvImport *DotenvImportPreview `json:"dotenvImport,omitempty"`
+	OAuth2       *OAuth2Config        `json:"oauth2,omitempty"`
+	OAuth2Token  *OAuth2TokenInfo     `json:"oauth2Token,omitempty"`
+	Hooks        []HookResult         `json:"hooks,omitempty"`
+	Workspaces   *WorkspaceList       `json:"workspaces,omitempty"`
+	Workspace    *Workspace           `json:"workspace,omitempty"`
+	Tree         *TreeNode            `json:"tree,omitempty"`
+	Matches      []FileMatch          `json:"matches,omitempty"`
+	Search       *SearchResult        `json:"search,omitempty"`
+	Replace      *ReplacePreview      `json:"replace,omitempty"`
+	Transfers    []PathTransfer       `json:"transfers,omitempty"`
+	Trash        []TrashItem          `json:"trash,omitempty"`
+	// ErrorCode classifies Error, see the ErrCode* constants.
+	ErrorCode    string               `json:"errorCode,omitempty"`
+	Confirmation *ConfirmationRequest `json:"confirmation,omitempty"`
 }
 
 type App struct {
@@ -54,6 +86,14 @@ type App struct {
     explorerState FileExplorerState
     cacheDB       *bolt.DB
     preferences   Preferences
+    vault         secretVault
+    revealed      revealStore
+    envWatch      envWatcher
+    fileIndex     fileIndex
+    selectedWatch selectedWatcher
+    confirmations confirmStore
+    // stopWatchers cancels the background file watchers on shutdown.
+    stopWatchers  context.CancelFunc
 }
 
 // Preferences represents simple persisted user settings.
@@ -62,6 +102,20 @@ type Preferences struct {
     LastOpenedFile string `json:"lastOpenedFile"`
     // LastOpenedDir stores the absolute path of the last browsed directory.
     LastOpenedDir  string `json:"lastOpenedDir"`
+    // AllowShellVariables enables {{$shell ...}} dynamic env values.
+    AllowShellVariables bool `json:"allowShellVariables"`
+    // AllowHooks enables the pre-run and post-run hook commands.
+    AllowHooks bool `json:"allowHooks"`
+    // Workspaces holds every workspace opened once, with its state.
+    Workspaces []Workspace `json:"workspaces,omitempty"`
+    // OpenWorkspaces lists the roots of the open workspaces.
+    OpenWorkspaces []string `json:"openWorkspaces,omitempty"`
+    // ActiveWorkspace is the root of the workspace shown, if any.
+    ActiveWorkspace string `json:"activeWorkspace,omitempty"`
+    // RecentWorkspaces lists workspace roots, most recently used first.
+    RecentWorkspaces []string `json:"recentWorkspaces,omitempty"`
+    // RecentFiles lists the files opened outside workspaces, most recent first.
+    RecentFiles []string `json:"recentFiles,omitempty"`
 }
 
 func NewApp() *App {
@@ -87,7 +141,10 @@ func (a *App) startup(ctx context.Context) {
 
     // Load preferences and restore last session state.
     if err := a.loadPreferences(); err == nil {
-        if a.preferences.LastOpenedFile != "" {
+        a.seedWorkspace()
+        if ws := a.activeWorkspace(); ws != nil {
+            a.restoreWorkspace(ws)
+        } else if a.preferences.LastOpenedFile != "" {
             if _, err := os.Stat(a.preferences.LastOpenedFile); err == nil {
                 // If the last opened file still exists, set current dir and selection.
                 dir := filepath.Dir(a.preferences.LastOpenedFile)
@@ -95,7 +152,7 @@ func (a *App) startup(ctx context.Context) {
                     a.explorerState.CurrentDir = dirInfo
                 }
                 if fi, err := createFileInfo(a.preferences.LastOpenedFile); err == nil {
-                    a.SetCurrentFile(a.ctx, fi)
+                    a.setCurrentFile(fi)
                 }
             }
         } else if a.preferences.LastOpenedDir != "" {
@@ -106,9 +163,19 @@ func (a *App) startup(ctx context.Context) {
             }
         }
     }
+
+    // Reload env files edited outside the app and report changes of the workspace
+    watchCtx, cancel := context.WithCancel(ctx)
+    a.stopWatchers = cancel
+    a.syncWatchers()
+    go a.watchEnvFiles(watchCtx)
+    go a.watchFiles(watchCtx)
 }
 
 func (a *App) shutdown(ctx context.Context) {
+    if a.stopWatchers != nil {
+        a.stopWatchers()
+    }
 }
 
 func (a *App) initCache() error {
@@ -174,9 +241,13 @@ func (a *App) selectedFileOutputPath() string {
 	// return filepath.Join(TEMP_DIR_PATH, a.explorerState.SelectedFile.Path)
 }
 
-func (a *App) selectedFileReportPath() string {
-	return filepath.Join(a.selectedFileOutputPath(), "report.json")
+// reportPathFor returns the location of the cached JSON report for a .hurl file.
+func reportPathFor(filePath string) string {
+	return filepath.Join(tempOutputPathFor(filePath), "report.json")
+}
 
+func (a *App) selectedFileReportPath() string {
+	return reportPathFor(a.explorerState.SelectedFile.Path)
 }
 
 func (a *App) selectedFileStorePath() string {
@@ -184,7 +255,6 @@ func (a *App) selectedFileStorePath() string {
 }
 
 func (a *App) insertResponseData(h *HurlReport, filePath string) error {
-	fmt.Println("Inserting response data from files in:", h)
 	outputDir := a.selectedFileOutputPath()
 
 	for i := range *h {
@@ -290,31 +360,128 @@ func (a *App) loadEnvConfig() (*EnvConfig, error) {
 		return nil, err
 	}
 
-	if _, err := os.Stat(envConfigPath); os.IsNotExist(err) {
+	config, err := readEnvConfigFile(envConfigPath)
+	if os.IsNotExist(err) {
 		return &EnvConfig{
-			Global:       make(map[string]string),
-			Environments: make(map[string]map[string]string),
+			Version:      envConfigVersion,
+			Global:       make(map[string]EnvValue),
+			Environments: make(map[string]map[string]EnvValue),
 		}, nil
 	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env config: %w", err)
+	}
+
+	return config, nil
+}
+
+func (a *App) saveEnvConfig(config *EnvConfig) error {
+	envConfigPath, err := a.getEnvFilePath()
+	if err != nil {
+		return err
+	}
 
-	data, err := os.ReadFile(envConfigPath)
+	data, err := marshalEnvConfig(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read env config file: %w", err)
+		return err
+	}
+	if err := writeFileAtomic(envConfigPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write env config: %w", err)
 	}
+	return nil
+}
 
-	var config EnvConfig
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse env config: %w", err)
+// expandedVariables resolves the variables of envName for filePath with
+// secrets decrypted and the dynamic values of the variables in used evaluated.
+func (a *App) expandedVariables(filePath string, envName string, used map[string]bool) (map[string]string, map[string]ResolvedVariable, error) {
+	resolved, err := a.resolveVariables(filePath, envName, true)
+	if err != nil {
+		return nil, nil, err
 	}
+	vars := make(map[string]string, len(resolved))
+	for name, v := range resolved {
+		vars[name] = v.Value
+	}
+	if err := a.expandDynamicVariables(vars, resolved, filePath, used); err != nil {
+		return nil, nil, err
+	}
+	return vars, resolved, nil
+}
 
-	if config.Global == nil {
-		config.Global = make(map[string]string)
+// referencedVariables returns the names of the {{name}} templates and of the
+// variable "name" queries of content.
+func referencedVariables(content string) map[string]bool {
+	names := map[string]bool{}
+	for _, m := range templateVarRe.FindAllStringSubmatch(content, -1) {
+		names[m[1]] = true
 	}
-	if config.Environments == nil {
-		config.Environments = make(map[string]map[string]string)
+	for _, m := range variableQueryRe.FindAllStringSubmatch(content, -1) {
+		names[m[1]] = true
 	}
+	return names
+}
 
-	return &config, nil
+// runVariables builds the variables passed to hurl for filePath from every
+// env layer, secrets decrypted, dynamic values evaluated and the OAuth2 token
+// of the environment added. Only the variables the file references are
+// evaluated and passed. Values are written so that hurl reads them with their
+// declared type. The values of the secrets visible from the file and the
+// OAuth2 tokens are returned too, to be redacted.
+func (a *App) runVariables(filePath string, envName string) (map[string]string, []string, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	fileUses := referencedVariables(string(content))
+	config, err := a.oauthConfig(filePath, envName)
+	if err != nil {
+		return nil, nil, err
+	}
+	used := map[string]bool{}
+	for name := range fileUses {
+		used[name] = true
+	}
+	if config != nil {
+		for name := range config.references() {
+			used[name] = true
+		}
+	}
+
+	vars, resolved, err := a.expandedVariables(filePath, envName, used)
+	if err != nil {
+		return nil, nil, err
+	}
+	secrets, err := a.injectOAuth2Token(config, vars)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, v := range resolved {
+		if v.Secret {
+			secrets = append(secrets, v.Value)
+		}
+	}
+	for name, value := range vars {
+		if !fileUses[name] {
+			delete(vars, name)
+			continue
+		}
+		vars[name] = EnvValue{Type: resolved[name].Type, Value: value}.hurlLiteral()
+	}
+	return vars, secrets, nil
+}
+
+// variableNames returns the variables visible from filePath for analysis,
+// secrets included with empty values so they are never decrypted.
+func (a *App) variableNames(filePath string, envName string) (map[string]string, error) {
+	resolved, err := a.resolveVariables(filePath, envName, false)
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string, len(resolved))
+	for name, v := range resolved {
+		vars[name] = v.Value
+	}
+	return vars, nil
 }
 
 // func (a *App) GetAvailableEnvGroups() ReturnValue {
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// newTestApp returns an app whose home, config and cache live in a temporary
+// folder.
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, ".local", "share"))
+
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "cache.db"), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("failed to open cache: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return &App{
+		explorerState: FileExplorerState{CurrentDir: FileInfo{Name: "Home", Path: home, IsDir: true}},
+		cacheDB:       db,
+	}
+}
+
+func TestRunVariablesSecrets(t *testing.T) {
+	a := newTestApp(t)
+	err := a.saveEnvConfig(&EnvConfig{
+		Global:       map[string]EnvValue{"host": {Value: "localhost"}},
+		Environments: map[string]map[string]EnvValue{"base": {}, "dev": {}},
+		Extends:      map[string]string{"dev": "base"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := a.UnlockVault("passphrase"); result.Error != "" {
+		t.Fatalf("UnlockVault: %s", result.Error)
+	}
+	for scope, value := range map[string]string{"": "global-secret", "base": "inherited-secret"} {
+		if result := a.SetSecret(scope, scope+"token", value); result.Error != "" {
+			t.Fatalf("SetSecret: %s", result.Error)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "login.hurl")
+	os.WriteFile(path, []byte("GET http://{{host}}/{{basetoken}}\n"), 0644)
+
+	vars, secrets, err := a.runVariables(path, "dev")
+	if err != nil {
+		t.Fatalf("runVariables: %v", err)
+	}
+	if vars["basetoken"] != "inherited-secret" {
+		t.Errorf("basetoken = %q", vars["basetoken"])
+	}
+	// Secrets of every scope in the chain are redacted, used or not
+	sort.Strings(secrets)
+	if got := strings.Join(secrets, ","); got != "global-secret,inherited-secret" {
+		t.Errorf("secrets = %s", got)
+	}
+}
+
+func TestReferencedVariables(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"templates", "GET http://{{host}}/{{ path }}\n", "host,path"},
+		{"assert query", "GET http://x\nHTTP 200\n[Asserts]\nvariable \"expected\" == 1\n", "expected"},
+		{"capture query", "GET http://x\nHTTP 200\n[Captures]\ncopy: variable \"token\"\n", "token"},
+		{"none", "GET http://x\n", ""},
+	}
+	for _, tt := range tests {
+		var names []string
+		for name := range referencedVariables(tt.content) {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if got := strings.Join(names, ","); got != tt.want {
+			t.Errorf("%s: referencedVariables = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	CompletionKindVariable    = "variable"
+	CompletionKindHeader      = "header"
+	CompletionKindHeaderValue = "headerValue"
+	CompletionKindSection     = "section"
+	CompletionKindQuery       = "query"
+	CompletionKindPredicate   = "predicate"
+	CompletionKindFilter      = "filter"
+	CompletionKindOption      = "option"
+)
+
+type CompletionItem struct {
+	Label  string `json:"label"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// CompletionList holds the suggestions for a cursor position. From is the
+// 1-based column where the word being completed starts. Columns count UTF-16
+// code units, as the editor does.
+type CompletionList struct {
+	From  int              `json:"from"`
+	Items []CompletionItem `json:"items"`
+}
+
+var requestSections = []string{
+	"QueryStringParams", "Query", "FormParams", "Form",
+	"MultipartFormData", "Multipart", "Cookies", "BasicAuth", "Options",
+}
+
+var responseSections = []string{"Captures", "Asserts"}
+
+var commonHeaders = map[string][]string{
+	"Accept":          {"application/json", "application/xml", "text/html", "text/plain", "*/*"},
+	"Accept-Encoding": {"gzip", "deflate", "br", "identity"},
+	"Accept-Language": {"en-US", "en"},
+	"Authorization":   {"Bearer ", "Basic "},
+	"Cache-Control":   {"no-cache", "no-store", "max-age=0"},
+	"Connection":      {"keep-alive", "close"},
+	"Content-Type": {
+		"application/json", "application/x-www-form-urlencoded", "multipart/form-data",
+		"application/xml", "text/plain", "text/html", "application/octet-stream",
+	},
+	"Cookie":            {},
+	"Host":              {},
+	"If-Match":          {},
+	"If-None-Match":     {},
+	"Origin":            {},
+	"Referer":           {},
+	"User-Agent":        {"hurl"},
+	"X-Request-Id":      {"{{newUuid}}"},
+	"X-Requested-With":  {"XMLHttpRequest"},
+	"X-Forwarded-For":   {},
+	"X-Api-Key":         {},
+	"X-Correlation-Id":  {"{{newUuid}}"},
+	"Content-Length":    {},
+	"Content-Encoding":  {"gzip", "deflate", "br"},
+	"If-Modified-Since": {},
+}
+
+// Queries, with whether they take a quoted argument.
+var assertQueries = map[string]bool{
+	"status": false, "version": false, "url": false, "ip": false,
+	"header": true, "cookie": true, "body": false, "bytes": false,
+	"xpath": true, "jsonpath": true, "regex": true, "variable": true,
+	"duration": false, "sha256": false, "md5": false, "certificate": true,
+	"redirects": false,
+}
+
+var assertPredicates = []string{
+	"==", "!=", ">", ">=", "<", "<=",
+	"startsWith", "endsWith", "contains", "includes", "matches", "exists",
+	"isBoolean", "isCollection", "isDate", "isEmpty", "isFloat", "isInteger",
+	"isIsoDate", "isIpv4", "isIpv6", "isList", "isNumber", "isObject", "isString",
+	"not",
+}
+
+var queryFilters = []string{
+	"base64Decode", "base64Encode", "count", "daysAfterNow", "daysBeforeNow",
+	"decode", "first", "format", "htmlEscape", "htmlUnescape", "jsonpath",
+	"last", "nth", "regex", "replace", "split", "toDate", "toFloat", "toInt",
+	"toString", "urlDecode", "urlEncode", "xpath",
+}
+
+var optionKeys = []string{
+	"aws-sigv4", "cacert", "cert", "compressed", "connect-timeout", "delay",
+	"http1.0", "http1.1", "http2", "http3", "insecure", "ipv4", "ipv6", "key",
+	"limit-rate", "location", "location-trusted", "max-redirs", "max-time",
+	"netrc", "netrc-file", "netrc-optional", "output", "path-as-is", "proxy",
+	"repeat", "resolve", "retry", "retry-interval", "skip", "unix-socket",
+	"user", "variable", "verbose", "very-verbose",
+}
+
+var (
+	// A {{ not closed before the cursor
+	openTemplateRe = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_\-]*)$`)
+	// Text of a line made only of a section being typed
+	sectionPrefixRe = regexp.MustCompile(`^\s*\[?([A-Za-z]*)$`)
+	headerNameRe    = regexp.MustCompile(`^\s*([A-Za-z0-9\-]*)$`)
+	headerValueRe   = regexp.MustCompile(`^\s*([A-Za-z0-9\-]+)\s*:\s*(.*)$`)
+	captureNameRe   = regexp.MustCompile(`^\s*[A-Za-z0-9_\-.]+\s*:\s*`)
+	quotedArgRe     = regexp.MustCompile(`^"(?:[^"\\]|\\.)*"`)
+)
+
+// completionContext is what completions are computed from: the parsed file,
+// the 1-based cursor line and the text of the line before the cursor.
+type completionContext struct {
+	parsed *hurlFile
+	line   int
+	before string
+	vars   map[string]string
+}
+
+func (c *completionContext) info() hurlLine {
+	if c.line-1 < len(c.parsed.Info) {
+		return c.parsed.Info[c.line-1]
+	}
+	return hurlLine{}
+}
+
+// inResponse reports whether the cursor is after the response line of its entry.
+func (c *completionContext) inResponse() bool {
+	for _, e := range c.parsed.Entries {
+		if c.line >= e.StartLine && c.line <= e.EndLine+1 {
+			return e.ResponseLine > 0 && c.line > e.ResponseLine
+		}
+	}
+	return false
+}
+
+// variablesBefore returns the variables available at the cursor line.
+func (c *completionContext) variablesBefore() []CompletionItem {
+	var items []CompletionItem
+	for name := range c.vars {
+		items = append(items, CompletionItem{Label: name, Kind: CompletionKindVariable, Detail: VariableSourceEnv})
+	}
+	for _, e := range c.parsed.Entries {
+		for _, capture := range e.Captures {
+			if capture.Line < c.line && !hasKey(c.vars, capture.Name) {
+				items = append(items, CompletionItem{Label: capture.Name, Kind: CompletionKindVariable, Detail: VariableSourceCapture})
+			}
+		}
+	}
+	for name := range builtinVariables {
+		items = append(items, CompletionItem{Label: name, Kind: CompletionKindVariable, Detail: VariableSourceBuiltin})
+	}
+	return items
+}
+
+// completeQuery completes the assert or capture query in text, which starts at
+// column offset of the line.
+func completeQuery(text string, offset int) CompletionList {
+	trimmed := strings.TrimLeft(text, " \t")
+	offset += len(text) - len(trimmed)
+
+	word, rest, found := strings.Cut(trimmed, " ")
+	if !found {
+		return CompletionList{From: offset + 1, Items: keywordItems(assertQueries, CompletionKindQuery, word)}
+	}
+	takesArg, known := assertQueries[word]
+	if !known {
+		return CompletionList{From: offset + 1 + len(trimmed), Items: []CompletionItem{}}
+	}
+	if takesArg {
+		rest = strings.TrimLeft(rest, " \t")
+		arg := quotedArgRe.FindString(rest)
+		if arg == "" {
+			// Still typing the query argument
+			return CompletionList{From: offset + 1 + len(trimmed), Items: []CompletionItem{}}
+		}
+		rest = rest[len(arg):]
+	}
+
+	// After the query: filters and predicates, completing the last word
+	last := rest[strings.LastIndexAny(rest, " \t")+1:]
+	items := listItems(queryFilters, CompletionKindFilter, last)
+	items = append(items, listItems(assertPredicates, CompletionKindPredicate, last)...)
+	return CompletionList{From: offset + 1 + len(trimmed) - len(last), Items: items}
+}
+
+func listItems(labels []string, kind string, prefix string) []CompletionItem {
+	items := []CompletionItem{}
+	for _, label := range labels {
+		if strings.HasPrefix(strings.ToLower(label), strings.ToLower(prefix)) {
+			items = append(items, CompletionItem{Label: label, Kind: kind})
+		}
+	}
+	return items
+}
+
+func keywordItems(keywords map[string]bool, kind string, prefix string) []CompletionItem {
+	labels := make([]string, 0, len(keywords))
+	for k := range keywords {
+		labels = append(labels, k)
+	}
+	sort.Strings(labels)
+	return listItems(labels, kind, prefix)
+}
+
+func (c *completionContext) complete() CompletionList {
+	before := c.before
+	info := c.info()
+
+	if info.Comment || strings.HasPrefix(strings.TrimSpace(before), "#") {
+		return CompletionList{From: len(before) + 1, Items: []CompletionItem{}}
+	}
+
+	// Variables anywhere, bodies included
+	if m := openTemplateRe.FindStringSubmatchIndex(before); m != nil {
+		prefix := before[m[2]:m[3]]
+		items := []CompletionItem{}
+		for _, item := range c.variablesBefore() {
+			if strings.HasPrefix(item.Label, prefix) {
+				items = append(items, item)
+			}
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+		return CompletionList{From: m[2] + 1, Items: items}
+	}
+	if info.InBody {
+		return CompletionList{From: len(before) + 1, Items: []CompletionItem{}}
+	}
+
+	// Sections only at line start
+	if m := sectionPrefixRe.FindStringSubmatchIndex(before); m != nil && strings.Contains(before, "[") {
+		sections := requestSections
+		if c.inResponse() {
+			sections = responseSections
+		}
+		items := []CompletionItem{}
+		for _, item := range listItems(sections, CompletionKindSection, before[m[2]:m[3]]) {
+			item.Label = "[" + item.Label + "]"
+			items = append(items, item)
+		}
+		return CompletionList{From: strings.Index(before, "[") + 1, Items: items}
+	}
+
+	switch info.Section {
+	case "Asserts":
+		return completeQuery(before, 0)
+	case "Captures":
+		if loc := captureNameRe.FindStringIndex(before); loc != nil {
+			return completeQuery(before[loc[1]:], loc[1])
+		}
+		return CompletionList{From: len(before) + 1, Items: []CompletionItem{}}
+	case "Options":
+		if m := headerNameRe.FindStringSubmatchIndex(before); m != nil {
+			return CompletionList{From: m[2] + 1, Items: listItems(optionKeys, CompletionKindOption, before[m[2]:m[3]])}
+		}
+		return CompletionList{From: len(before) + 1, Items: []CompletionItem{}}
+	case "":
+		return c.completeHeader()
+	}
+	return CompletionList{From: len(before) + 1, Items: []CompletionItem{}}
+}
+
+func (c *completionContext) completeHeader() CompletionList {
+	before := c.before
+	if m := headerValueRe.FindStringSubmatchIndex(before); m != nil {
+		name := before[m[2]:m[3]]
+		prefix := before[m[4]:m[5]]
+		for header, values := range commonHeaders {
+			if strings.EqualFold(header, name) {
+				return CompletionList{From: m[4] + 1, Items: listItems(values, CompletionKindHeaderValue, prefix)}
+			}
+		}
+		return CompletionList{From: m[4] + 1, Items: []CompletionItem{}}
+	}
+
+	// Header names only make sense inside an entry
+	if c.info().Entry == 0 {
+		return CompletionList{From: len(before) + 1, Items: []CompletionItem{}}
+	}
+	if m := headerNameRe.FindStringSubmatchIndex(before); m != nil {
+		names := make([]string, 0, len(commonHeaders))
+		for header := range commonHeaders {
+			names = append(names, header)
+		}
+		sort.Strings(names)
+		items := listItems(names, CompletionKindHeader, before[m[2]:m[3]])
+		if strings.TrimSpace(before) == "" {
+			// Sections are also valid at an empty line start
+			sections := requestSections
+			if c.inResponse() {
+				sections = responseSections
+			}
+			for _, section := range sections {
+				items = append(items, CompletionItem{Label: "[" + section + "]", Kind: CompletionKindSection})
+			}
+		}
+		return CompletionList{From: m[2] + 1, Items: items}
+	}
+	return CompletionList{From: len(before) + 1, Items: []CompletionItem{}}
+}
+
+// GetCompletions returns completion suggestions for the editor content at the
+// given 1-based line and column. Variables come from the merged env config of
+// envName and from the captures defined before the cursor.
+func (a *App) GetCompletions(content string, line int, column int, envName string) ReturnValue {
+	parsed := parseHurlFile(content)
+	if line < 1 || line > len(parsed.Lines) {
+		return ReturnValue{Error: fmt.Sprintf("line out of range: %d", line)}
+	}
+	text := parsed.Lines[line-1]
+	offset, ok := utf16ToByteOffset(text, column-1)
+	if column < 1 || !ok {
+		return ReturnValue{Error: fmt.Sprintf("column out of range: %d", column)}
+	}
+
+	vars, err := a.variableNames(a.envStart(), envName)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+
+	ctx := completionContext{
+		parsed: parsed,
+		line:   line,
+		before: text[:offset],
+		vars:   vars,
+	}
+	completions := ctx.complete()
+	completions.From = byteToUTF16Offset(text, completions.From-1) + 1
+	return ReturnValue{Completions: &completions}
+}
+
+// utf16ToByteOffset converts an offset in UTF-16 code units of text to a byte
+// offset. It fails past the end of text or inside a surrogate pair.
+func utf16ToByteOffset(text string, units int) (int, bool) {
+	count := 0
+	for i, r := range text {
+		if count == units {
+			return i, true
+		}
+		if count > units {
+			return 0, false
+		}
+		count += utf16.RuneLen(r)
+	}
+	return len(text), count == units
+}
+
+// byteToUTF16Offset converts a byte offset of text to UTF-16 code units.
+func byteToUTF16Offset(text string, offset int) int {
+	units := 0
+	for i, r := range text {
+		if i >= offset {
+			break
+		}
+		units += utf16.RuneLen(r)
+	}
+	return units
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ${VAR}, ${VAR:-default} and $VAR at the start of a string
+var dotenvInterpolationRe = regexp.MustCompile(`^(?:\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}|\$([A-Za-z_][A-Za-z0-9_]*))`)
+
+// Dotenv keys may contain dots, which variable names may not: such keys are
+// parsed, usable in interpolation, and skipped by the import.
+var dotenvKeyRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+
+type OverwrittenVariable struct {
+	Name     string `json:"name"`
+	OldValue string `json:"oldValue"`
+	NewValue string `json:"newValue"`
+}
+
+// DotenvImportPreview lists what importing a dotenv file into EnvName changes.
+// Skipped are the keys that are not valid variable names and are not imported.
+type DotenvImportPreview struct {
+	EnvName     string                `json:"envName"`
+	NewEnv      bool                  `json:"newEnv"`
+	Variables   map[string]string     `json:"variables"`
+	Added       []string              `json:"added"`
+	Overwritten []OverwrittenVariable `json:"overwritten"`
+	Unchanged   []string              `json:"unchanged"`
+	Skipped     []string              `json:"skipped"`
+}
+
+// parseDotenv parses dotenv syntax: KEY=value lines with optional "export",
+// # comments, single quotes (literal), double quotes (escapes, may span
+// lines) and ${VAR} interpolation of earlier keys or the OS environment.
+func parseDotenv(content string) (map[string]string, error) {
+	vars := map[string]string{}
+	lookup := func(name string) (string, bool) {
+		if v, ok := vars[name]; ok {
+			return v, true
+		}
+		return os.LookupEnv(name)
+	}
+	// interpolate expands s in a single pass, unescaping it too when escapes
+	// is set, so that an escaped \$ stays a literal dollar and interpolated
+	// values are never unescaped.
+	interpolate := func(s string, escapes bool) string {
+		var b strings.Builder
+		for i := 0; i < len(s); i++ {
+			switch {
+			case escapes && s[i] == '\\' && i+1 < len(s):
+				b.WriteString(unescapeDotenv(s[i : i+2]))
+				i++
+			case s[i] == '$':
+				m := dotenvInterpolationRe.FindStringSubmatch(s[i:])
+				if m == nil {
+					b.WriteByte('$')
+					continue
+				}
+				name := m[1]
+				if name == "" {
+					name = m[3]
+				}
+				if v, ok := lookup(name); ok && v != "" {
+					b.WriteString(v)
+				} else {
+					b.WriteString(m[2])
+				}
+				i += len(m[0]) - 1
+			default:
+				b.WriteByte(s[i])
+			}
+		}
+		return b.String()
+	}
+
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		key, rest, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("line %d: expected KEY=value", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		if !dotenvKeyRe.MatchString(key) {
+			return nil, fmt.Errorf("line %d: invalid key %q", lineNo, key)
+		}
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value string
+		switch {
+		case strings.HasPrefix(rest, "'"):
+			end := strings.Index(rest[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quote", lineNo)
+			}
+			value = rest[1 : end+1]
+		case strings.HasPrefix(rest, `"`):
+			// Double quoted values may continue on the next lines
+			raw := rest[1:]
+			for {
+				if end := closingQuote(raw); end >= 0 {
+					raw = raw[:end]
+					break
+				}
+				i++
+				if i >= len(lines) {
+					return nil, fmt.Errorf("line %d: unterminated double quote", lineNo)
+				}
+				raw += "\n" + lines[i]
+			}
+			value = interpolate(raw, true)
+		default:
+			// Unquoted: an inline comment starts at " #"
+			if idx := strings.Index(rest, " #"); idx >= 0 {
+				rest = rest[:idx]
+			}
+			value = interpolate(strings.TrimSpace(rest), false)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// closingQuote returns the index of the first unescaped double quote, or -1.
+func closingQuote(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeDotenv(s string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`, `\$`, "$")
+	return replacer.Replace(s)
+}
+
+// dotenvEnvName derives an environment name from a dotenv file name:
+// ".env.staging" and "staging.env" give "staging", ".env" gives "default".
+func dotenvEnvName(filePath string) string {
+	base := filepath.Base(filePath)
+	switch {
+	case strings.HasPrefix(base, ".env."):
+		return strings.TrimPrefix(base, ".env.")
+	case strings.HasSuffix(base, ".env") && base != ".env":
+		return strings.TrimSuffix(base, ".env")
+	}
+	return "default"
+}
+
+func (a *App) planDotenvImport(filePath string, envName string) (*DotenvImportPreview, error) {
+	filePath, err := a.sandboxPath(filePath, false)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	vars, err := parseDotenv(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(filePath), err)
+	}
+	skipped := []string{}
+	for name := range vars {
+		if validateVariableName(name) != nil {
+			skipped = append(skipped, name)
+			delete(vars, name)
+		}
+	}
+	sort.Strings(skipped)
+
+	if envName == "" {
+		envName = dotenvEnvName(filePath)
+	}
+	if err := validateEnvName(envName); err != nil {
+		return nil, err
+	}
+	config, err := a.loadEnvConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	existing, exists := config.Environments[envName]
+	preview := &DotenvImportPreview{
+		EnvName:     envName,
+		NewEnv:      !exists,
+		Variables:   vars,
+		Added:       []string{},
+		Overwritten: []OverwrittenVariable{},
+		Unchanged:   []string{},
+		Skipped:     skipped,
+	}
+	for name, value := range vars {
+		old, ok := existing[name]
+		switch {
+		case !ok:
+			preview.Added = append(preview.Added, name)
+		case old != untypedValue(value):
+			preview.Overwritten = append(preview.Overwritten, OverwrittenVariable{Name: name, OldValue: old.Value, NewValue: value})
+		default:
+			preview.Unchanged = append(preview.Unchanged, name)
+		}
+	}
+	sort.Strings(preview.Added)
+	sort.Strings(preview.Unchanged)
+	sort.Slice(preview.Overwritten, func(i, j int) bool { return preview.Overwritten[i].Name < preview.Overwritten[j].Name })
+	return preview, nil
+}
+
+// PreviewDotenvImport parses a dotenv file and lists the variables it adds to
+// or overwrites in envName. An empty envName is derived from the file name.
+func (a *App) PreviewDotenvImport(filePath string, envName string) ReturnValue {
+	preview, err := a.planDotenvImport(filePath, envName)
+	if err != nil {
+		return errorResult(err)
+	}
+	return ReturnValue{DotenvImport: preview}
+}
+
+// ImportDotenv imports a dotenv file as a new environment, or merges it into
+// an existing one, overwriting the variables it defines.
+func (a *App) ImportDotenv(filePath string, envName string) ReturnValue {
+	preview, err := a.planDotenvImport(filePath, envName)
+	if err != nil {
+		return errorResult(err)
+	}
+	result := a.updateEnvConfig(func(config *EnvConfig) error {
+		vars := config.Environments[preview.EnvName]
+		if vars == nil {
+			vars = map[string]EnvValue{}
+			config.Environments[preview.EnvName] = vars
+		}
+		for name, value := range preview.Variables {
+			vars[name] = untypedValue(value)
+		}
+		return nil
+	})
+	result.DotenvImport = preview
+	return result
+}
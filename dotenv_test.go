@@ -0,0 +1,104 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	t.Setenv("DOTENV_TEST_HOME", "/home/alice")
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "plain values, export and comments",
+			content: "# comment\nexport HOST=localhost\nPORT = 8080 # inline\n\nEMPTY=\n",
+			want:    map[string]string{"HOST": "localhost", "PORT": "8080", "EMPTY": ""},
+		},
+		{
+			name:    "single quotes are literal",
+			content: `A='$HOME \n # not a comment'`,
+			want:    map[string]string{"A": `$HOME \n # not a comment`},
+		},
+		{
+			name:    "double quotes unescape",
+			content: `A="tab\tquote\" backslash\\ dollar\$"`,
+			want:    map[string]string{"A": "tab\tquote\" backslash\\ dollar$"},
+		},
+		{
+			name:    "multiline double quotes",
+			content: "KEY=\"-----BEGIN KEY-----\nabc\n-----END KEY-----\"\r\nNEXT=1\n",
+			want:    map[string]string{"KEY": "-----BEGIN KEY-----\nabc\n-----END KEY-----", "NEXT": "1"},
+		},
+		{
+			name:    "interpolation of earlier keys and the environment",
+			content: "HOST=localhost\nURL=http://${HOST}:$DOTENV_TEST_UNSET/x\nDIR=\"$DOTENV_TEST_HOME/api\"\n",
+			want:    map[string]string{"HOST": "localhost", "URL": "http://localhost:/x", "DIR": "/home/alice/api"},
+		},
+		{
+			name:    "defaults",
+			content: "A=${MISSING:-fallback}\nB=\"${DOTENV_TEST_HOME:-unused}\"\n",
+			want:    map[string]string{"A": "fallback", "B": "/home/alice"},
+		},
+		{
+			name:    "escaped dollar is not interpolated",
+			content: "HOST=localhost\nA=\"\\$HOST and \\${HOST} cost \\$5\"\n",
+			want:    map[string]string{"HOST": "localhost", "A": "$HOST and ${HOST} cost $5"},
+		},
+		{
+			name:    "interpolated values are not unescaped",
+			content: "RAW='a\\nb'\nA=\"${RAW}\"\n",
+			want:    map[string]string{"RAW": `a\nb`, "A": `a\nb`},
+		},
+		{
+			name:    "lone dollar kept",
+			content: "A=\"5$ and $\"\nB=$\n",
+			want:    map[string]string{"A": "5$ and $", "B": "$"},
+		},
+		{
+			name:    "dotted keys",
+			content: "app.name=studio\n",
+			want:    map[string]string{"app.name": "studio"},
+		},
+		{
+			name:    "missing equal sign",
+			content: "JUSTAKEY\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid key",
+			content: "1KEY=a\n",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated single quote",
+			content: "A='open\n",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated double quote",
+			content: "A=\"open\nstill open\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDotenv(tt.content)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseDotenv = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDotenv: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDotenv = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
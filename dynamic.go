@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	mathrand "math/rand/v2"
+	"os"
+	"os/exec"
+	"regexp"
+	goruntime "runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Env values can contain dynamic variables, evaluated again for every run:
+//
+//	{{$uuid}}              random UUID v4
+//	{{$timestamp}}         Unix time in seconds
+//	{{$isoDate}}           current UTC time, RFC 3339
+//	{{$randomInt}}         random integer in [0, 1000), or {{$randomInt min max}}
+//	{{$randomEmail}}       fake email address
+//	{{$randomFirstName}}   fake first name
+//	{{$randomLastName}}    fake last name
+//	{{$randomFullName}}    fake first and last name
+//	{{$env NAME}}          value of an OS environment variable
+//	{{$shell command}}     output of a shell command, off unless enabled in preferences
+//
+// $env and $shell can leak secrets or run anything, and env files of a project
+// come with the repository. They are only evaluated in the user's own env
+// config and vault, or in the project env files of the active workspace once
+// the user trusted it with SetAllowShellVariables. Only the variables a run
+// uses are evaluated.
+
+const shellVariableTimeout = 10 * time.Second
+
+var dynamicVarRe = regexp.MustCompile(`\{\{\s*\$([A-Za-z]+)(?:\s+([^}]*?))?\s*\}\}`)
+
+var fakeFirstNames = []string{
+	"Alice", "Bob", "Carol", "Dave", "Eve", "Frank", "Grace", "Heidi",
+	"Ivan", "Judy", "Mallory", "Niaj", "Olivia", "Peggy", "Rupert", "Sybil",
+	"Trent", "Victor", "Walter", "Yuki",
+}
+
+var fakeLastNames = []string{
+	"Anderson", "Brown", "Clark", "Davis", "Evans", "Fischer", "Garcia",
+	"Hughes", "Ito", "Jones", "Kowalski", "Lopez", "Martin", "Nguyen",
+	"Okafor", "Patel", "Rossi", "Smith", "Tanaka", "Weber",
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func pick(values []string) string {
+	return values[mathrand.IntN(len(values))]
+}
+
+func runShellVariable(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), shellVariableTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if goruntime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("shell variable %q failed: %w", command, err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// dynamicPolicy tells which of $env and $shell a value may use.
+type dynamicPolicy struct {
+	env   bool
+	shell bool
+}
+
+// evalDynamic returns the value of one dynamic variable.
+func evalDynamic(name string, args string, policy dynamicPolicy) (string, error) {
+	switch name {
+	case "uuid":
+		return newUUID(), nil
+	case "timestamp":
+		return strconv.FormatInt(time.Now().Unix(), 10), nil
+	case "isoDate":
+		return time.Now().UTC().Format(time.RFC3339), nil
+	case "randomInt":
+		min, max := 0, 1000
+		if fields := strings.Fields(args); len(fields) == 2 {
+			var err1, err2 error
+			min, err1 = strconv.Atoi(fields[0])
+			max, err2 = strconv.Atoi(fields[1])
+			if err1 != nil || err2 != nil || max <= min {
+				return "", fmt.Errorf("invalid $randomInt range: %q", args)
+			}
+		} else if len(fields) != 0 {
+			return "", fmt.Errorf("$randomInt takes no argument or min and max: %q", args)
+		}
+		return strconv.Itoa(min + mathrand.IntN(max-min)), nil
+	case "randomEmail":
+		return fmt.Sprintf("%s.%s.%d@example.com",
+			strings.ToLower(pick(fakeFirstNames)), strings.ToLower(pick(fakeLastNames)), mathrand.IntN(100000)), nil
+	case "randomFirstName":
+		return pick(fakeFirstNames), nil
+	case "randomLastName":
+		return pick(fakeLastNames), nil
+	case "randomFullName":
+		return pick(fakeFirstNames) + " " + pick(fakeLastNames), nil
+	case "env":
+		if !policy.env {
+			return "", fmt.Errorf("$env is only allowed in your env config or a trusted workspace, not in project files")
+		}
+		if args == "" {
+			return "", fmt.Errorf("$env needs a variable name")
+		}
+		return os.Getenv(args), nil
+	case "shell":
+		if !policy.shell {
+			return "", fmt.Errorf("shell variables are disabled, enable them in preferences or trust the workspace to run %q", args)
+		}
+		if args == "" {
+			return "", fmt.Errorf("$shell needs a command")
+		}
+		return runShellVariable(args)
+	}
+	return "", fmt.Errorf("unknown dynamic variable: $%s", name)
+}
+
+// expandDynamic replaces the dynamic variables of a value.
+func expandDynamic(value string, policy dynamicPolicy) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+	var firstErr error
+	expanded := dynamicVarRe.ReplaceAllStringFunc(value, func(match string) string {
+		m := dynamicVarRe.FindStringSubmatch(match)
+		v, err := evalDynamic(m[1], strings.TrimSpace(m[2]), policy)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return match
+		}
+		return v
+	})
+	return expanded, firstErr
+}
+
+// dynamicPolicy returns what the value of v, used by filePath, may evaluate.
+func (a *App) dynamicPolicy(v ResolvedVariable, filePath string) dynamicPolicy {
+	switch v.Layer {
+	case EnvLayerGlobal, EnvLayerSecret:
+		return dynamicPolicy{env: true, shell: a.preferences.AllowShellVariables}
+	}
+	if ws := a.activeWorkspace(); ws != nil && isWithin(ws.Root, filePath) && ws.Settings.AllowShellVariables {
+		return dynamicPolicy{env: true, shell: true}
+	}
+	return dynamicPolicy{}
+}
+
+// expandDynamicVariables evaluates the dynamic variables of the values in
+// used, in place.
+func (a *App) expandDynamicVariables(vars map[string]string, resolved map[string]ResolvedVariable, filePath string, used map[string]bool) error {
+	for name, value := range vars {
+		if !used[name] {
+			continue
+		}
+		expanded, err := expandDynamic(value, a.dynamicPolicy(resolved[name], filePath))
+		if err != nil {
+			return fmt.Errorf("variable %s: %w", name, err)
+		}
+		vars[name] = expanded
+	}
+	return nil
+}
+
+// SetAllowShellVariables turns {{$shell ...}} env values on or off. With an
+// active workspace, it trusts or distrusts the project env files of the
+// workspace with $env and $shell; otherwise it applies to the user env config.
+func (a *App) SetAllowShellVariables(allow bool) ReturnValue {
+	if ws := a.activeWorkspace(); ws != nil {
+		ws.Settings.AllowShellVariables = allow
+	} else {
+		a.preferences.AllowShellVariables = allow
+	}
+	if err := a.savePreferences(); err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	return ReturnValue{}
+}
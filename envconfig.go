@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// envConfigVersion is the env.json format written by this version of the app.
+// Files without a version predate versioning and are read as version 0.
+const envConfigVersion = 1
+
+// EnvConfigError is an invalid env file, with the position of the error when
+// it is known.
+type EnvConfigError struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e *EnvConfigError) Error() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", e.Path, e.Line, e.Column, e.Message)
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// lineColumn converts a byte offset of data to a 1-based line and column.
+func lineColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// parseEnvConfig decodes and migrates an env config read from path.
+func parseEnvConfig(path string, data []byte) (*EnvConfig, error) {
+	var config EnvConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		parseErr := &EnvConfigError{Path: path, Message: err.Error()}
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			parseErr.Line, parseErr.Column = lineColumn(data, syntaxErr.Offset)
+		case errors.As(err, &typeErr):
+			parseErr.Line, parseErr.Column = lineColumn(data, typeErr.Offset)
+		}
+		return nil, parseErr
+	}
+	if err := migrateEnvConfig(&config); err != nil {
+		return nil, &EnvConfigError{Path: path, Message: err.Error()}
+	}
+	if config.Global == nil {
+		config.Global = make(map[string]EnvValue)
+	}
+	if config.Environments == nil {
+		config.Environments = make(map[string]map[string]EnvValue)
+	}
+	return &config, nil
+}
+
+// migrateEnvConfig upgrades config to envConfigVersion one version at a time.
+// A format change bumps envConfigVersion and adds the step from the previous one.
+func migrateEnvConfig(config *EnvConfig) error {
+	if config.Version > envConfigVersion {
+		return fmt.Errorf("env config version %d is newer than the supported version %d", config.Version, envConfigVersion)
+	}
+	for config.Version < envConfigVersion {
+		switch config.Version {
+		case 0:
+			// Version 1 only adds the version field: plain string values
+			// already read as untyped values
+		}
+		config.Version++
+	}
+	return nil
+}
+
+// marshalEnvConfig encodes config as written to env.json, at the current version.
+func marshalEnvConfig(config *EnvConfig) ([]byte, error) {
+	config.Version = envConfigVersion
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal env config: %w", err)
+	}
+	return data, nil
+}
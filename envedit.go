@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Bindings editing env.json. Variable functions take an envName, an empty
+// envName targets the global variables.
+
+func validateEnvName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("environment name is empty")
+	}
+	if name != strings.TrimSpace(name) {
+		return fmt.Errorf("environment name must not start or end with spaces: %q", name)
+	}
+	if name == globalScope {
+		return fmt.Errorf("environment name %q is reserved", name)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("environment name contains control characters: %q", name)
+		}
+	}
+	return nil
+}
+
+func validateVariableName(name string) error {
+	if !validVariableNameRe.MatchString(name) {
+		return fmt.Errorf("invalid variable name %q: use letters, digits, '_' or '-' and do not start with a digit", name)
+	}
+	return nil
+}
+
+func sortedEnvNames(config *EnvConfig) []string {
+	names := make([]string, 0, len(config.Environments))
+	for name := range config.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// scopeVars returns the variables of envName, or the globals when envName is empty.
+func (c *EnvConfig) scopeVars(envName string) (map[string]EnvValue, error) {
+	if envName == "" {
+		return c.Global, nil
+	}
+	vars, ok := c.Environments[envName]
+	if !ok {
+		return nil, fmt.Errorf("environment does not exist: %s", envName)
+	}
+	if vars == nil {
+		vars = map[string]EnvValue{}
+		c.Environments[envName] = vars
+	}
+	return vars, nil
+}
+
+// updateEnvConfig loads the env config, applies update and saves the result.
+func (a *App) updateEnvConfig(update func(config *EnvConfig) error) ReturnValue {
+	config, err := a.loadEnvConfig()
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	// Updates move secrets along with their environment, so the vault is put
+	// back if env.json cannot be saved
+	vault, err := a.loadVaultFile()
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	if err := update(config); err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	if err := a.saveEnvConfig(config); err != nil {
+		if restoreErr := a.restoreVaultFile(vault); restoreErr != nil {
+			fmt.Printf("failed to restore vault: %v\n", restoreErr)
+		}
+		return ReturnValue{Error: err.Error()}
+	}
+	return ReturnValue{EnvConfig: config, Envs: sortedEnvNames(config)}
+}
+
+// GetEnvConfig returns the whole env config with the global variables and
+// every environment.
+func (a *App) GetEnvConfig() ReturnValue {
+	config, err := a.loadEnvConfig()
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	return ReturnValue{EnvConfig: config, Envs: sortedEnvNames(config)}
+}
+
+func (a *App) CreateEnvironment(name string) ReturnValue {
+	return a.updateEnvConfig(func(config *EnvConfig) error {
+		if err := validateEnvName(name); err != nil {
+			return err
+		}
+		if _, exists := config.Environments[name]; exists {
+			return fmt.Errorf("environment already exists: %s", name)
+		}
+		config.Environments[name] = map[string]EnvValue{}
+		return nil
+	})
+}
+
+func (a *App) RenameEnvironment(oldName string, newName string) ReturnValue {
+	return a.updateEnvConfig(func(config *EnvConfig) error {
+		vars, exists := config.Environments[oldName]
+		if !exists {
+			return fmt.Errorf("environment does not exist: %s", oldName)
+		}
+		if err := validateEnvName(newName); err != nil {
+			return err
+		}
+		if _, exists := config.Environments[newName]; exists {
+			return fmt.Errorf("environment already exists: %s", newName)
+		}
+		delete(config.Environments, oldName)
+		config.Environments[newName] = vars
+		if parent, ok := config.Extends[oldName]; ok {
+			delete(config.Extends, oldName)
+			config.Extends[newName] = parent
+		}
+		for child, parent := range config.Extends {
+			if parent == oldName {
+				config.Extends[child] = newName
+			}
+		}
+		if oauth, ok := config.OAuth2[oldName]; ok {
+			delete(config.OAuth2, oldName)
+			config.OAuth2[newName] = oauth
+		}
+		return a.copySecretScope(oldName, &newName, false)
+	})
+}
+
+// DuplicateEnvironment copies the variables and secrets of sourceName into a new environment.
+func (a *App) DuplicateEnvironment(sourceName string, newName string) ReturnValue {
+	return a.updateEnvConfig(func(config *EnvConfig) error {
+		vars, exists := config.Environments[sourceName]
+		if !exists {
+			return fmt.Errorf("environment does not exist: %s", sourceName)
+		}
+		if err := validateEnvName(newName); err != nil {
+			return err
+		}
+		if _, exists := config.Environments[newName]; exists {
+			return fmt.Errorf("environment already exists: %s", newName)
+		}
+		copied := make(map[string]EnvValue, len(vars))
+		for k, v := range vars {
+			copied[k] = v
+		}
+		config.Environments[newName] = copied
+		if parent, ok := config.Extends[sourceName]; ok {
+			config.Extends[newName] = parent
+		}
+		if oauth, ok := config.OAuth2[sourceName]; ok {
+			oauth.Scopes = append([]string(nil), oauth.Scopes...)
+			config.OAuth2[newName] = oauth
+		}
+		return a.copySecretScope(sourceName, &newName, true)
+	})
+}
+
+func (a *App) DeleteEnvironment(name string) ReturnValue {
+	return a.updateEnvConfig(func(config *EnvConfig) error {
+		if _, exists := config.Environments[name]; !exists {
+			return fmt.Errorf("environment does not exist: %s", name)
+		}
+		delete(config.Environments, name)
+		delete(config.OAuth2, name)
+		// Children inherit from the deleted environment's parent instead
+		parent := config.Extends[name]
+		delete(config.Extends, name)
+		for child, p := range config.Extends {
+			if p != name {
+				continue
+			}
+			if parent != "" {
+				config.Extends[child] = parent
+			} else {
+				delete(config.Extends, child)
+			}
+		}
+		return a.copySecretScope(name, nil, false)
+	})
+}
+
+// SetEnvironmentParent makes envName inherit the variables of parent. An empty
+// parent removes the inheritance.
+func (a *App) SetEnvironmentParent(envName string, parent string) ReturnValue {
+	return a.updateEnvConfig(func(config *EnvConfig) error {
+		if _, exists := config.Environments[envName]; !exists {
+			return fmt.Errorf("environment does not exist: %s", envName)
+		}
+		if parent == "" {
+			delete(config.Extends, envName)
+			return nil
+		}
+		if _, exists := config.Environments[parent]; !exists {
+			return fmt.Errorf("environment does not exist: %s", parent)
+		}
+		// Reject cycles: parent must not already inherit from envName
+		for p := parent; p != ""; p = config.Extends[p] {
+			if p == envName {
+				return fmt.Errorf("environment %q cannot extend %q: inheritance cycle", envName, parent)
+			}
+		}
+		if config.Extends == nil {
+			config.Extends = map[string]string{}
+		}
+		config.Extends[envName] = parent
+		return nil
+	})
+}
+
+// AddVariable adds a new variable, failing if the name is already used in that scope.
+func (a *App) AddVariable(envName string, name string, value EnvValue) ReturnValue {
+	return a.updateEnvConfig(func(config *EnvConfig) error {
+		vars, err := config.scopeVars(envName)
+		if err != nil {
+			return err
+		}
+		if err := validateVariableName(name); err != nil {
+			return err
+		}
+		if err := value.validate(); err != nil {
+			return err
+		}
+		if _, exists := vars[name]; exists {
+			return fmt.Errorf("variable already exists: %s", name)
+		}
+		vars[name] = value
+		return nil
+	})
+}
+
+// UpdateVariable changes the value and type of a variable, renaming it when
+// newName differs from name.
+func (a *App) UpdateVariable(envName string, name string, newName string, value EnvValue) ReturnValue {
+	return a.updateEnvConfig(func(config *EnvConfig) error {
+		vars, err := config.scopeVars(envName)
+		if err != nil {
+			return err
+		}
+		if err := value.validate(); err != nil {
+			return err
+		}
+		if _, exists := vars[name]; !exists {
+			return fmt.Errorf("variable does not exist: %s", name)
+		}
+		if newName != name {
+			if err := validateVariableName(newName); err != nil {
+				return err
+			}
+			if _, exists := vars[newName]; exists {
+				return fmt.Errorf("variable already exists: %s", newName)
+			}
+			delete(vars, name)
+		}
+		vars[newName] = value
+		return nil
+	})
+}
+
+func (a *App) DeleteVariable(envName string, name string) ReturnValue {
+	return a.updateEnvConfig(func(config *EnvConfig) error {
+		vars, err := config.scopeVars(envName)
+		if err != nil {
+			return err
+		}
+		if _, exists := vars[name]; !exists {
+			return fmt.Errorf("variable does not exist: %s", name)
+		}
+		delete(vars, name)
+		return nil
+	})
+}
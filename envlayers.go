@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Variables are resolved from layers, later layers overriding earlier ones:
+//
+//	global   ~/.config/hurlstudio/env.json, plus the secret vault
+//	project  <root>/.hurlstudio/env.json and <root>/.hurlstudio/<env>.env
+//	local    <root>/.hurlstudio/env.local.json, meant to be git-ignored
+//	folder   .hurlvars of each folder from <root> down to the file
+//	file     <name>.hurlvars next to <name>.hurl
+//
+// <root> is the closest parent folder of the file containing .hurlstudio.
+// .env and .hurlvars files use the hurl --variables-file format, one
+// name=value per line. .env files define the environment named after the file,
+// .hurlvars files apply whatever the environment.
+//
+// An environment can extend another one through "extends" in any env.json.
+// Globals of every layer are applied first, then each environment of the
+// chain from the farthest ancestor down to the selected one, so environment
+// values always beat globals. .hurlvars files are applied last.
+
+const (
+	EnvLayerGlobal  = "global"
+	EnvLayerSecret  = "secret"
+	EnvLayerProject = "project"
+	EnvLayerLocal   = "local"
+	EnvLayerFolder  = "folder"
+	EnvLayerFile    = "file"
+)
+
+const hurlVarsFileName = ".hurlvars"
+
+// envSource is one file of a layer, holding an env config.
+type envSource struct {
+	Layer  string
+	Path   string
+	Config *EnvConfig
+}
+
+// EnvLayerInfo describes a discovered env file for the UI.
+type EnvLayerInfo struct {
+	Layer string `json:"layer"`
+	Path  string `json:"path"`
+}
+
+// ResolvedVariable is the final value of a variable and where it came from.
+// Values of secrets are never filled in.
+type ResolvedVariable struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Type   string `json:"type,omitempty"`
+	Layer  string `json:"layer"`
+	Source string `json:"source"`
+	// Scope is "global" or the environment defining the value.
+	Scope  string `json:"scope"`
+	Secret bool   `json:"secret,omitempty"`
+	// Overrides lists the layers whose value was replaced, lowest first.
+	Overrides []string `json:"overrides,omitempty"`
+}
+
+// parseVariablesFile reads a hurl --variables-file: name=value lines, blank
+// lines and # comments.
+func parseVariablesFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, &EnvConfigError{Path: path, Line: lineNo, Message: "expected name=value"}
+		}
+		vars[strings.TrimSpace(name)] = value
+	}
+	return vars, scanner.Err()
+}
+
+func readEnvConfigFile(path string) (*EnvConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseEnvConfig(path, data)
+}
+
+// projectEnvSources returns the env files of the project containing start.
+func projectEnvSources(start string) ([]envSource, error) {
+	root := findProjectRoot(start)
+	if root == "" {
+		return nil, nil
+	}
+	dir := filepath.Join(root, projectConfigDirName)
+
+	var sources []envSource
+	if config, err := readEnvConfigFile(filepath.Join(dir, "env.json")); err == nil {
+		sources = append(sources, envSource{Layer: EnvLayerProject, Path: filepath.Join(dir, "env.json"), Config: config})
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	envFiles, _ := filepath.Glob(filepath.Join(dir, "*.env"))
+	sort.Strings(envFiles)
+	for _, path := range envFiles {
+		vars, err := parseVariablesFile(path)
+		if err != nil {
+			return nil, err
+		}
+		envName := strings.TrimSuffix(filepath.Base(path), ".env")
+		sources = append(sources, envSource{
+			Layer: EnvLayerProject,
+			Path:  path,
+			Config: &EnvConfig{
+				Global:       map[string]EnvValue{},
+				Environments: map[string]map[string]EnvValue{envName: untypedVars(vars)},
+			},
+		})
+	}
+
+	localPath := filepath.Join(dir, "env.local.json")
+	if config, err := readEnvConfigFile(localPath); err == nil {
+		sources = append(sources, envSource{Layer: EnvLayerLocal, Path: localPath, Config: config})
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// envSources returns every env file visible from start, lowest priority first.
+func (a *App) envSources(start string) ([]envSource, error) {
+	config, err := a.loadEnvConfig()
+	if err != nil {
+		return nil, err
+	}
+	globalPath, err := a.getEnvFilePath()
+	if err != nil {
+		return nil, err
+	}
+	sources := []envSource{{Layer: EnvLayerGlobal, Path: globalPath, Config: config}}
+
+	project, err := projectEnvSources(start)
+	if err != nil {
+		return nil, err
+	}
+	return append(sources, project...), nil
+}
+
+// envNames returns the environments defined by any layer.
+func envNames(sources []envSource) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, source := range sources {
+		for name := range source.Config.Environments {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// envChain returns envName preceded by the environments it extends, farthest
+// ancestor first.
+func envChain(sources []envSource, envName string) ([]string, error) {
+	extends := map[string]string{}
+	for _, source := range sources {
+		for child, parent := range source.Config.Extends {
+			extends[child] = parent
+		}
+	}
+
+	chain := []string{}
+	seen := map[string]bool{}
+	for name := envName; name != ""; name = extends[name] {
+		if seen[name] {
+			return nil, fmt.Errorf("environment %q has an inheritance cycle", envName)
+		}
+		seen[name] = true
+		chain = append([]string{name}, chain...)
+	}
+	return chain, nil
+}
+
+// hurlVarsPaths returns the .hurlvars files that may apply to filePath, found
+// or not: one per folder from the project root down to the file, then the
+// file's own.
+func hurlVarsPaths(filePath string) []EnvLayerInfo {
+	if filePath == "" {
+		return nil
+	}
+	info, err := os.Stat(filePath)
+	if err != nil || info.IsDir() {
+		return nil
+	}
+	dir := filepath.Dir(filePath)
+	root := findProjectRoot(dir)
+	if root == "" {
+		root = dir
+	}
+
+	// Folders from the root down to the file
+	var dirs []string
+	for d := dir; ; d = filepath.Dir(d) {
+		dirs = append([]string{d}, dirs...)
+		if d == root || filepath.Dir(d) == d {
+			break
+		}
+	}
+
+	paths := make([]EnvLayerInfo, 0, len(dirs)+1)
+	for _, d := range dirs {
+		paths = append(paths, EnvLayerInfo{Layer: EnvLayerFolder, Path: filepath.Join(d, hurlVarsFileName)})
+	}
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	return append(paths, EnvLayerInfo{Layer: EnvLayerFile, Path: filepath.Join(dir, name+hurlVarsFileName)})
+}
+
+// overrideSources returns the .hurlvars files applying to filePath, lowest
+// priority first.
+func overrideSources(filePath string) ([]envSource, error) {
+	var sources []envSource
+	for _, candidate := range hurlVarsPaths(filePath) {
+		vars, err := parseVariablesFile(candidate.Path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, envSource{
+			Layer:  candidate.Layer,
+			Path:   candidate.Path,
+			Config: &EnvConfig{Global: untypedVars(vars), Environments: map[string]map[string]EnvValue{}},
+		})
+	}
+	return sources, nil
+}
+
+// resolveVariables resolves the variables of envName for a file. Secrets are
+// decrypted only when decrypt is set, otherwise they resolve to empty values.
+func (a *App) resolveVariables(start string, envName string, decrypt bool) (map[string]ResolvedVariable, error) {
+	sources, err := a.envSources(start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load env config: %w", err)
+	}
+
+	resolved := map[string]ResolvedVariable{}
+	set := func(v ResolvedVariable) {
+		if previous, ok := resolved[v.Name]; ok {
+			v.Overrides = append(append([]string{}, previous.Overrides...), previous.Layer)
+		}
+		resolved[v.Name] = v
+	}
+
+	chain, err := envChain(sources, envName)
+	if err != nil {
+		return nil, err
+	}
+	scopes := append([]string{""}, chain...)
+	for _, scope := range scopes {
+		for i, source := range sources {
+			vars := source.Config.Global
+			if scope != "" {
+				vars = source.Config.Environments[scope]
+			}
+			for k, v := range vars {
+				set(ResolvedVariable{Name: k, Value: v.Value, Type: v.Type, Layer: source.Layer, Source: source.Path, Scope: secretScope(scope)})
+			}
+
+			// Secrets belong to the user, right after the global layer
+			if i != 0 {
+				continue
+			}
+			if decrypt {
+				secrets, err := a.decryptSecrets(scope)
+				if err != nil {
+					return nil, err
+				}
+				for k, v := range secrets {
+					set(ResolvedVariable{Name: k, Value: v, Layer: EnvLayerSecret, Source: EnvLayerSecret, Scope: secretScope(scope), Secret: true})
+				}
+			} else {
+				for _, k := range a.scopeSecretNames(scope) {
+					set(ResolvedVariable{Name: k, Layer: EnvLayerSecret, Source: EnvLayerSecret, Scope: secretScope(scope), Secret: true})
+				}
+			}
+		}
+	}
+
+	overrides, err := overrideSources(start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load variable overrides: %w", err)
+	}
+	for _, source := range overrides {
+		for k, v := range source.Config.Global {
+			set(ResolvedVariable{Name: k, Value: v.Value, Type: v.Type, Layer: source.Layer, Source: source.Path, Scope: globalScope})
+		}
+	}
+	return resolved, nil
+}
+
+// layerSources returns every env file used for start, .hurlvars included,
+// lowest priority first.
+func (a *App) layerSources(start string) ([]envSource, error) {
+	sources, err := a.envSources(start)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := overrideSources(start)
+	if err != nil {
+		return nil, err
+	}
+	return append(sources, overrides...), nil
+}
+
+func envLayerInfos(sources []envSource) []EnvLayerInfo {
+	layers := make([]EnvLayerInfo, 0, len(sources))
+	for _, source := range sources {
+		layers = append(layers, EnvLayerInfo{Layer: source.Layer, Path: source.Path})
+	}
+	return layers
+}
+
+// GetEnvLayers returns the env files used for filePath, lowest priority first.
+func (a *App) GetEnvLayers(filePath string) ReturnValue {
+	filePath, err := a.sandboxStart(filePath)
+	if err != nil {
+		return errorResult(err)
+	}
+	sources, err := a.layerSources(filePath)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	return ReturnValue{EnvLayers: envLayerInfos(sources), Envs: envNames(sources)}
+}
+
+// ResolveVariables returns the final variables of envName for filePath, with
+// the layer, file and environment each value came from. Secret values are not
+// included.
+func (a *App) ResolveVariables(filePath string, envName string) ReturnValue {
+	filePath, err := a.sandboxStart(filePath)
+	if err != nil {
+		return errorResult(err)
+	}
+	resolved, err := a.resolveVariables(filePath, envName, false)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	variables := make([]ResolvedVariable, 0, len(resolved))
+	for _, v := range resolved {
+		variables = append(variables, v)
+	}
+	sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+	return ReturnValue{Resolved: variables}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// Hurl infers the type of a variable from its text: true/false are booleans,
+// null is null, numbers are numbers and anything else, or text in double
+// quotes, is a string. EnvValue keeps the type of a variable so that the
+// string "42" and the number 42 reach hurl as such.
+//
+// In env.json a plain JSON string is an untyped value passed to hurl as is,
+// which is how every variable was stored before types existed. JSON numbers,
+// booleans and null are typed, and {"type": "string", "value": "42"} forces a
+// string.
+
+const (
+	EnvValueUntyped = ""
+	EnvValueString  = "string"
+	EnvValueNumber  = "number"
+	EnvValueBoolean = "boolean"
+	EnvValueNull    = "null"
+)
+
+// Decimal numbers only: ParseFloat alone also takes NaN, Inf and hex floats.
+var decimalNumberRe = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// isNumber reports whether s is a finite decimal number.
+func isNumber(s string) bool {
+	if !decimalNumberRe.MatchString(s) {
+		return false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return err == nil && !math.IsInf(f, 0)
+}
+
+type EnvValue struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func untypedValue(value string) EnvValue {
+	return EnvValue{Value: value}
+}
+
+func (v EnvValue) validate() error {
+	switch v.Type {
+	case EnvValueUntyped, EnvValueString:
+	case EnvValueNumber:
+		if !isNumber(v.Value) {
+			return fmt.Errorf("invalid number: %q", v.Value)
+		}
+	case EnvValueBoolean:
+		if v.Value != "true" && v.Value != "false" {
+			return fmt.Errorf("invalid boolean: %q", v.Value)
+		}
+	case EnvValueNull:
+		if v.Value != "" && v.Value != "null" {
+			return fmt.Errorf("null variables have no value: %q", v.Value)
+		}
+	default:
+		return fmt.Errorf("unknown variable type: %q", v.Type)
+	}
+	return nil
+}
+
+// inferredByHurl reports whether hurl would read s as something else than a string.
+func inferredByHurl(s string) bool {
+	if s == "true" || s == "false" || s == "null" {
+		return true
+	}
+	if isNumber(s) {
+		return true
+	}
+	return len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"'
+}
+
+// hurlLiteral returns the text to pass to hurl so it gets the value's type.
+func (v EnvValue) hurlLiteral() string {
+	switch v.Type {
+	case EnvValueString:
+		if inferredByHurl(v.Value) {
+			// Hurl strips the surrounding quotes and keeps a string
+			return `"` + v.Value + `"`
+		}
+		return v.Value
+	case EnvValueNull:
+		return "null"
+	}
+	return v.Value
+}
+
+func (v EnvValue) MarshalJSON() ([]byte, error) {
+	switch v.Type {
+	case EnvValueNumber, EnvValueBoolean:
+		if err := v.validate(); err != nil {
+			return nil, err
+		}
+		return []byte(v.Value), nil
+	case EnvValueNull:
+		return []byte("null"), nil
+	case EnvValueString:
+		if inferredByHurl(v.Value) {
+			type typed EnvValue
+			return json.Marshal(typed(v))
+		}
+	}
+	return json.Marshal(v.Value)
+}
+
+func (v *EnvValue) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return fmt.Errorf("empty variable value")
+	}
+	switch data[0] {
+	case '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*v = untypedValue(s)
+	case 't', 'f':
+		var b bool
+		if err := json.Unmarshal(data, &b); err != nil {
+			return err
+		}
+		*v = EnvValue{Type: EnvValueBoolean, Value: strconv.FormatBool(b)}
+	case 'n':
+		*v = EnvValue{Type: EnvValueNull}
+	case '{':
+		type typed EnvValue
+		var t typed
+		if err := json.Unmarshal(data, &t); err != nil {
+			return err
+		}
+		*v = EnvValue(t)
+		if v.Type == EnvValueNull {
+			v.Value = ""
+		}
+		return v.validate()
+	default:
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("invalid variable value: %s", data)
+		}
+		*v = EnvValue{Type: EnvValueNumber, Value: n.String()}
+	}
+	return nil
+}
+
+// untypedVars wraps plain string variables, as read from .env files.
+func untypedVars(vars map[string]string) map[string]EnvValue {
+	typed := make(map[string]EnvValue, len(vars))
+	for k, v := range vars {
+		typed[k] = untypedValue(v)
+	}
+	return typed
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// The env files are polled rather than followed through the file index: they
+// are a handful, some live outside the workspace like the user env.json, and
+// the set of files changes with the selected file. Stating them every second
+// costs next to nothing.
+
+const (
+	// EventEnvChanged carries an EnvChangedEvent after an env file changed.
+	EventEnvChanged = "env:changed"
+	// EventEnvError carries an EnvConfigError when a changed env file is invalid.
+	EventEnvError = "env:error"
+)
+
+const envWatchInterval = time.Second
+
+type EnvChangedEvent struct {
+	Envs   []string       `json:"envs"`
+	Layers []EnvLayerInfo `json:"layers"`
+}
+
+type fileStamp struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// envWatcher remembers the env files seen by the last poll. Only start is
+// shared with the bindings, the rest belongs to the polling goroutine.
+type envWatcher struct {
+	mu    sync.Mutex
+	start string
+
+	watched string
+	stamps  map[string]fileStamp
+}
+
+// setStart sets the file or folder whose env files are watched.
+func (w *envWatcher) setStart(start string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.start = start
+}
+
+// poll stats the env files of the watched start and reports whether one was
+// created, modified or removed since the previous poll. A new start is only
+// recorded: its env files did not change, they were just not watched yet.
+func (w *envWatcher) poll(paths func(start string) []string) (string, bool) {
+	w.mu.Lock()
+	start := w.start
+	w.mu.Unlock()
+
+	stamps := map[string]fileStamp{}
+	for _, path := range paths(start) {
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = fileStamp{ModTime: info.ModTime(), Size: info.Size()}
+		}
+	}
+
+	changed := w.stamps != nil && start == w.watched && !sameStamps(w.stamps, stamps)
+	w.watched = start
+	w.stamps = stamps
+	return start, changed
+}
+
+func sameStamps(a map[string]fileStamp, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, stamp := range a {
+		if other, ok := b[path]; !ok || !other.ModTime.Equal(stamp.ModTime) || other.Size != stamp.Size {
+			return false
+		}
+	}
+	return true
+}
+
+// envStart returns where project environments are looked up from: the selected
+// file, or the current directory.
+func (a *App) envStart() string {
+	if a.explorerState.SelectedFile.Path != "" {
+		return a.explorerState.SelectedFile.Path
+	}
+	return a.explorerState.CurrentDir.Path
+}
+
+// syncWatchers points the background watchers at the current selection and
+// workspace.
+func (a *App) syncWatchers() {
+	a.envWatch.setStart(a.envStart())
+	a.fileIndex.setRoot(a.indexRoot())
+	a.selectedWatch.setPath(a.explorerState.SelectedFile.Path)
+}
+
+// envWatchPaths returns every env file that can affect start, whether it
+// exists or not, so that new files are noticed too.
+func (a *App) envWatchPaths(start string) []string {
+	var paths []string
+	if globalPath, err := a.getEnvFilePath(); err == nil {
+		paths = append(paths, globalPath)
+	}
+	if root := findProjectRoot(start); root != "" {
+		dir := filepath.Join(root, projectConfigDirName)
+		paths = append(paths, filepath.Join(dir, "env.json"), filepath.Join(dir, "env.local.json"))
+		envFiles, _ := filepath.Glob(filepath.Join(dir, "*.env"))
+		paths = append(paths, envFiles...)
+	}
+	for _, candidate := range hurlVarsPaths(start) {
+		paths = append(paths, candidate.Path)
+	}
+	return paths
+}
+
+// watchEnvFiles polls the env files until ctx is done, emitting
+// EventEnvChanged or EventEnvError when one of them changes.
+func (a *App) watchEnvFiles(ctx context.Context) {
+	ticker := time.NewTicker(envWatchInterval)
+	defer ticker.Stop()
+
+	a.envWatch.poll(a.envWatchPaths)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if start, changed := a.envWatch.poll(a.envWatchPaths); changed {
+				a.emitEnvState(ctx, start)
+			}
+		}
+	}
+}
+
+func (a *App) emitEnvState(ctx context.Context, start string) {
+	sources, err := a.layerSources(start)
+	if err != nil {
+		var configErr *EnvConfigError
+		if !errors.As(err, &configErr) {
+			configErr = &EnvConfigError{Message: err.Error()}
+		}
+		runtime.EventsEmit(ctx, EventEnvError, configErr)
+		return
+	}
+	runtime.EventsEmit(ctx, EventEnvChanged, EnvChangedEvent{
+		Envs:   envNames(sources),
+		Layers: envLayerInfos(sources),
+	})
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -18,6 +18,9 @@ func (a *App) GetCurrentDirectory() FileInfo {
 }
 
 func (a *App) GetFiles() ReturnValue {
+	if _, err := a.sandboxPath(a.explorerState.CurrentDir.Path, false); err != nil {
+		return errorResult(err)
+	}
 	entries, err := os.ReadDir(a.explorerState.CurrentDir.Path)
 	if err != nil {
 		return ReturnValue{Error: err.Error()}
@@ -43,13 +46,16 @@ func (a *App) GetFiles() ReturnValue {
 	return ReturnValue{Files: files, FileExplorer: a.explorerState}
 }
 
-func (a *App) SetCurrentFile(ctx context.Context, file FileInfo) {
+// setCurrentFile selects file, which the caller checked with sandboxPath.
+func (a *App) setCurrentFile(file FileInfo) {
     a.explorerState.SelectedFile = file
-    runtime.WindowSetTitle(ctx, file.Path)
+    a.syncWatchers()
+    runtime.WindowSetTitle(a.ctx, file.Path)
 
     // Persist last opened file if valid
     if file.Path != "" {
         a.preferences.LastOpenedFile = file.Path
+        a.recordOpenedFile(file.Path)
         if err := a.savePreferences(); err != nil {
             // Non-fatal: log to console but don't interrupt flow
             fmt.Printf("failed to save preferences: %v\n", err)
@@ -58,6 +64,10 @@ func (a *App) SetCurrentFile(ctx context.Context, file FileInfo) {
 }
 
 func (a *App) ChangeDirectory(path string) ReturnValue {
+    path, err := a.sandboxPath(path, false)
+    if err != nil {
+        return errorResult(err)
+    }
     fileInfo, err := createFileInfo(path)
     if err != nil {
         return ReturnValue{Error: err.Error()}
@@ -69,28 +79,43 @@ func (a *App) ChangeDirectory(path string) ReturnValue {
 	}
 
     a.explorerState.CurrentDir = fileInfo
+    a.syncWatchers()
     a.preferences.LastOpenedDir = fileInfo.Path
+    a.recordOpenedDir(fileInfo.Path)
     if err := a.savePreferences(); err != nil {
         fmt.Printf("failed to save preferences after change dir: %v\n", err)
     }
     return ReturnValue{FileExplorer: a.explorerState}
 }
 
+// NavigateUp goes to the parent folder, stopping at the workspace root.
 func (a *App) NavigateUp() ReturnValue {
-	parent := filepath.Dir(a.explorerState.CurrentDir.Path)
-	if parent == a.explorerState.CurrentDir.Path {
+	current := a.explorerState.CurrentDir.Path
+	if resolved, err := resolvePath(current); err == nil {
+		for _, root := range a.allowedRoots() {
+			if resolved == root {
+				return ReturnValue{Error: "already at root directory"}
+			}
+		}
+	}
+	parent := filepath.Dir(current)
+	if parent == current {
 		return ReturnValue{Error: "already at root directory"}
 	}
 	return a.ChangeDirectory(parent)
 }
 
 func (a *App) SelectFile(filePath string) ReturnValue {
+	filePath, err := a.sandboxPath(filePath, false)
+	if err != nil {
+		return errorResult(err)
+	}
 	fileInfo, err := createFileInfo(filePath)
 	if err != nil {
 		return ReturnValue{Error: fmt.Sprintf("file does not exist: %s", filePath)}
 	}
 
-	a.SetCurrentFile(a.ctx, fileInfo)
+	a.setCurrentFile(fileInfo)
 	// a.explorerState.SelectedFile = fileInfo
 	return ReturnValue{
 		FileExplorer: a.explorerState,
@@ -102,7 +127,7 @@ func (a *App) GetSelectedFile() FileInfo {
 }
 
 func (a *App) ClearSelection() {
-	a.SetCurrentFile(a.ctx, FileInfo{})
+	a.setCurrentFile(FileInfo{})
 	// a.explorerState.SelectedFile = FileInfo{}
 }
 
@@ -113,6 +138,10 @@ func (a *App) GetExplorerState() ReturnValue {
 }
 
 func (a *App) GetFileContent(filePath string) ReturnValue {
+	filePath, err := a.sandboxPath(filePath, false)
+	if err != nil {
+		return errorResult(err)
+	}
 
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -132,11 +161,14 @@ func (a *App) ExecuteHurl(filePath string, envName string) ReturnValue {
 
 	// Create dir if not exists
 	if err := os.MkdirAll(TEMP_DIR_PATH, 0755); err != nil {
-		return ReturnValue{Error: fmt.Sprintf("failed to create temp dir: %w", err)}
+		return ReturnValue{Error: fmt.Sprintf("failed to create temp dir: %v", err)}
 	}
 
+	if _, err := a.sandboxPath(a.explorerState.SelectedFile.Path, false); err != nil {
+		return errorResult(err)
+	}
 	if _, err := os.Stat(a.explorerState.SelectedFile.Path); err != nil {
-		return ReturnValue{Error: fmt.Sprintf("file does not exist: %w", err)}
+		return ReturnValue{Error: fmt.Sprintf("file does not exist: %v", err)}
 	}
 
 	outputDir := a.selectedFileOutputPath()
@@ -148,43 +180,72 @@ func (a *App) ExecuteHurl(filePath string, envName string) ReturnValue {
 	os.Remove(reportPath)
 	os.MkdirAll(outputDir, 0755)
 
+	a.recordEnv(envName)
+
 	// Build hurl command with env variables
-	// Load env config
-	config, err := a.loadEnvConfig()
+	vars, secrets, err := a.runVariables(a.explorerState.SelectedFile.Path, envName)
 	if err != nil {
-		return ReturnValue{Error: fmt.Sprintf("failed to load env config: %v", err)}
+		return ReturnValue{Error: err.Error()}
 	}
 
-	// Merge globals with selected environment (env overrides globals)
-	vars := map[string]string{}
-	for k, v := range config.Global {
-		vars[k] = v
+	// Pre-run hooks may add variables, post-run hooks get the report
+	preHooks, postHooks, err := loadHooks(a.explorerState.SelectedFile.Path)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
 	}
-	if envName != "" {
-		if envMap, ok := config.Environments[envName]; ok {
-			for k, v := range envMap {
-				vars[k] = v
-			}
-		}
+	if len(preHooks)+len(postHooks) > 0 && !a.allowHooks() {
+		return ReturnValue{Error: hooksDisabledError(preHooks, postHooks).Error()}
+	}
+	redactor := a.newRedactor(secrets)
+	hookEnvVars := hookEnv(a.explorerState.SelectedFile.Path, envName)
+	var hookResults []HookResult
+	runPost := func(exitCode int) {
+		env := append(append([]string{}, hookEnvVars...), "HURL_REPORT="+reportPath, fmt.Sprintf("HURL_EXIT_CODE=%d", exitCode))
+		hookResults = append(hookResults, runPostHooks(postHooks, env)...)
+		redactHookResults(redactor, hookResults)
+	}
+	hookResults, hookVars, err := runPreHooks(preHooks, hookEnvVars)
+	// Hooks typically output tokens, which must not show in results
+	for _, value := range hookVars {
+		redactor.addSecrets(value)
+	}
+	if err != nil {
+		// Post hooks still run, to clean up after the pre hooks that ran
+		runPost(-1)
+		return ReturnValue{Error: err.Error(), Hooks: hookResults}
+	}
+	for name, value := range hookVars {
+		vars[name] = value
 	}
 
-	command := []string{"hurl", "--report-json", outputDir}
-	// Append variables as --variable key=value
-	for k, v := range vars {
-		command = append(command, "--variable", fmt.Sprintf("%s=%s", k, v))
+	// Undefined variables are only warnings: the analysis cannot know every
+	// variable hurl defines, so hurl reports the ones really missing
+	var analysis *VariableAnalysis
+	if content, err := os.ReadFile(a.explorerState.SelectedFile.Path); err == nil {
+		result := analyzeVariables(string(content), vars)
+		analysis = &result
 	}
+
+	// Pass variables through a private file rather than --variable arguments
+	varsFile, varsEnv, err := writeVariablesFile(vars)
+	if err != nil {
+		runPost(-1)
+		return ReturnValue{Error: err.Error(), Hooks: hookResults, Variables: analysis}
+	}
+	defer os.Remove(varsFile)
+
+	command := []string{"hurl", "--report-json", outputDir, "--variables-file", varsFile}
 	// Finally the file path
 	command = append(command, a.explorerState.SelectedFile.Path)
 
 	cmd := exec.Command(command[0], command[1:]...)
-	bytes, err := cmd.CombinedOutput()
-	if err != nil {
-		return ReturnValue{Error: fmt.Sprintf("failed to execute hurl: %s\n%s", err.Error(), string(bytes))}
-	}
+	cmd.Env = append(os.Environ(), varsEnv...)
+	bytes, runErr := cmd.CombinedOutput()
 
 	// Read and parse JSON report from outputDir
 	var report HurlReport
-	if reportData, readErr := os.ReadFile(reportPath); readErr == nil {
+	reportData, readErr := os.ReadFile(reportPath)
+	if readErr == nil {
 		if parseErr := json.Unmarshal(reportData, &report); parseErr != nil {
 			fmt.Printf("Failed to parse JSON report: %v\n", parseErr)
 		}
@@ -192,15 +253,43 @@ func (a *App) ExecuteHurl(filePath string, envName string) ReturnValue {
 		fmt.Printf("Failed to read JSON report: %v\n", readErr)
 	}
 
-	marshalled, err := json.Marshal(report)
-	fmt.Println(string(marshalled))
+	// Keep the raw result in memory only, then redact what is stored and returned
+	raw := copyReport(report)
+	a.insertResponseData(&raw, outputBodyDir)
+	a.revealed.set(a.explorerState.SelectedFile.Path, raw)
+	redactor.report(report)
+	redactor.storeFiles(outputBodyDir)
+	if readErr == nil {
+		if data, err := json.Marshal(report); err == nil {
+			if err := writeFileAtomic(reportPath, data, 0644); err != nil {
+				fmt.Printf("Failed to write redacted JSON report: %v\n", err)
+			}
+		}
+	}
+
+	exitCode := 0
+	if runErr != nil {
+		exitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(runErr, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		}
+	}
+	runPost(exitCode)
+
+	if runErr != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to execute hurl: %s\n%s", runErr.Error(), redactor.text(string(bytes))), Hooks: hookResults, Variables: analysis}
+	}
 
 	a.insertResponseData(&report, outputBodyDir)
 
-	return ReturnValue{HurlReport: report}
+	return ReturnValue{HurlReport: report, Hooks: hookResults, Variables: analysis}
 }
 
 func (a *App) GetHurlResult(filePath string) ReturnValue {
+	if _, err := a.sandboxPath(a.explorerState.SelectedFile.Path, false); err != nil {
+		return errorResult(err)
+	}
 
 	reportPath := a.selectedFileReportPath()
 	outputPath := a.selectedFileOutputPath()
@@ -228,7 +317,10 @@ func (a *App) GetHurlResult(filePath string) ReturnValue {
 
 func (a *App) CreateNewFile(fileName string, fileContent string) ReturnValue {
 	// Create a new file in the current directory
-	filePath := filepath.Join(a.explorerState.CurrentDir.Path, fileName)
+	filePath, err := a.sandboxPath(filepath.Join(a.explorerState.CurrentDir.Path, fileName), true)
+	if err != nil {
+		return errorResult(err)
+	}
 
 	// Check if file already exists
 	if _, err := os.Stat(filePath); err == nil {
@@ -236,17 +328,17 @@ func (a *App) CreateNewFile(fileName string, fileContent string) ReturnValue {
 	}
 
 	if err := os.WriteFile(filePath, []byte(fileContent), 0644); err != nil {
-		return ReturnValue{Error: fmt.Sprintf("failed to create new file: %w", err)}
+		return ReturnValue{Error: fmt.Sprintf("failed to create new file: %v", err)}
 	}
 
 	newFile, err := createFileInfo(filePath)
 	if err != nil {
-		return ReturnValue{Error: fmt.Sprintf("failed to create file info: %w", err)}
+		return ReturnValue{Error: fmt.Sprintf("failed to create file info: %v", err)}
 	}
 
 	fmt.Println("New file created:", newFile.Name)
 
-	a.SetCurrentFile(a.ctx, newFile)
+	a.setCurrentFile(newFile)
 	// a.explorerState.SelectedFile = newFile
 
 	return ReturnValue{}
@@ -259,13 +351,18 @@ func (a *App) WriteToSelectedFile(content string) ReturnValue {
 	if filePath == "" {
 		return ReturnValue{Error: "no file selected"}
 	}
+	// The workspace may have been closed since the file was selected
+	filePath, err := a.sandboxPath(filePath, false)
+	if err != nil {
+		return errorResult(err)
+	}
 
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		return ReturnValue{Error: fmt.Sprintf("file does not exist: %s", filePath)}
 	}
 
 	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
-		return ReturnValue{Error: fmt.Sprintf("failed to write to file: %w", err)}
+		return ReturnValue{Error: fmt.Sprintf("failed to write to file: %v", err)}
 	}
 
 	return ReturnValue{}
@@ -273,7 +370,10 @@ func (a *App) WriteToSelectedFile(content string) ReturnValue {
 
 func (a *App) CreateFolder(folderName string) ReturnValue {
 	// Create a new folder in the current directory
-	folderPath := filepath.Join(a.explorerState.CurrentDir.Path, folderName)
+	folderPath, err := a.sandboxPath(filepath.Join(a.explorerState.CurrentDir.Path, folderName), true)
+	if err != nil {
+		return errorResult(err)
+	}
 
 	// Check if folder already exists
 	if _, err := os.Stat(folderPath); err == nil {
@@ -281,7 +381,7 @@ func (a *App) CreateFolder(folderName string) ReturnValue {
 	}
 
 	if err := os.Mkdir(folderPath, 0755); err != nil {
-		return ReturnValue{Error: fmt.Sprintf("failed to create new folder: %w", err)}
+		return ReturnValue{Error: fmt.Sprintf("failed to create new folder: %v", err)}
 	}
 
 	// Do not change current directory; just acknowledge success.
@@ -301,10 +401,13 @@ func (a *App) RenamePath(oldPath string, newName string) ReturnValue {
 	if filepath.Base(newName) != newName {
 		return ReturnValue{Error: "new name must not contain path separators"}
 	}
+	oldPath, err := a.sandboxPath(oldPath, true)
+	if err != nil {
+		return errorResult(err)
+	}
 
 	// Ensure the old path exists
-	oldInfo, err := os.Stat(oldPath)
-	if err != nil {
+	if _, err := os.Stat(oldPath); err != nil {
 		return ReturnValue{Error: fmt.Sprintf("path does not exist: %v", err)}
 	}
 
@@ -320,44 +423,24 @@ func (a *App) RenamePath(oldPath string, newName string) ReturnValue {
 		return ReturnValue{Error: fmt.Sprintf("failed to rename: %v", err)}
 	}
 
-	// Update explorer state if needed
-	if a.explorerState.SelectedFile.Path == oldPath {
-		a.explorerState.SelectedFile.Path = newPath
-		a.explorerState.SelectedFile.Name = filepath.Base(newPath)
-		a.explorerState.SelectedFile.IsDir = oldInfo.IsDir()
-		// Keep preferences in sync if the selected file was renamed
-		if !oldInfo.IsDir() {
-			a.preferences.LastOpenedFile = newPath
-			if err := a.savePreferences(); err != nil {
-				fmt.Printf("failed to save preferences after rename: %v\n", err)
-			}
-		}
+	// Keep the cached results, the explorer state and the preferences in sync
+	moveCache(oldPath, newPath, false)
+	a.pathMoved(oldPath, newPath)
+	if err := a.savePreferences(); err != nil {
+		fmt.Printf("failed to save preferences after rename: %v\n", err)
 	}
-    if a.explorerState.CurrentDir.Path == oldPath && oldInfo.IsDir() {
-        a.explorerState.CurrentDir.Path = newPath
-        a.explorerState.CurrentDir.Name = filepath.Base(newPath)
-        a.explorerState.CurrentDir.IsDir = true
-        a.preferences.LastOpenedDir = newPath
-        if err := a.savePreferences(); err != nil {
-            fmt.Printf("failed to save preferences after dir rename: %v\n", err)
-        }
-    }
 
 	return ReturnValue{FileExplorer: a.explorerState}
 }
 
 func (a *App) GetEnvVars() ReturnValue {
-	config, err := a.loadEnvConfig()
+	// Project environments depend on the selected file, or the current dir
+	sources, err := a.envSources(a.envStart())
 	if err != nil {
 		return ReturnValue{Error: err.Error()}
 	}
 
-	envSelectables := []string{}
-	for groupName := range config.Environments {
-		envSelectables = append(envSelectables, groupName)
-	}
-
-	return ReturnValue{Envs: envSelectables}
+	return ReturnValue{Envs: envNames(sources)}
 }
 
 func (a *App) GetEnvFilePath() ReturnValue {
@@ -370,19 +453,46 @@ func (a *App) GetEnvFilePath() ReturnValue {
 	return ReturnValue{EnvFilePath: envConfigPath}
 }
 
-// DeletePath deletes a file or directory. Directories are removed recursively.
+// DeletePath moves a file or directory to the trash. The returned transfer
+// gives its location in the trash, for RestoreFromTrash.
 func (a *App) DeletePath(targetPath string) ReturnValue {
-	if targetPath == "" {
-		return ReturnValue{Error: "path is empty"}
+	return a.deletePath(targetPath, false, false)
+}
+
+// DeletePathPermanently deletes a file or directory without going through the
+// trash. Directories are removed recursively.
+func (a *App) DeletePathPermanently(targetPath string) ReturnValue {
+	return a.deletePath(targetPath, true, false)
+}
+
+func (a *App) deletePath(targetPath string, permanent bool, confirmed bool) ReturnValue {
+	targetPath, err := a.sandboxPath(targetPath, true)
+	if err != nil {
+		return errorResult(err)
 	}
 
-	info, err := os.Stat(targetPath)
+	info, err := os.Lstat(targetPath)
 	if err != nil {
 		return ReturnValue{Error: fmt.Sprintf("path does not exist: %v", err)}
 	}
+	if info.IsDir() && !confirmed {
+		operation := operationTrash
+		if permanent {
+			operation = operationDelete
+		}
+		if result, required := a.requireConfirmation(operation, targetPath); required {
+			return result
+		}
+	}
 
-	// Perform deletion
-	if info.IsDir() {
+	var transfers []PathTransfer
+	if !permanent {
+		trashPath, err := a.trash(targetPath)
+		if err != nil {
+			return ReturnValue{Error: fmt.Sprintf("failed to move to trash: %v", err)}
+		}
+		transfers = append(transfers, PathTransfer{From: targetPath, To: trashPath})
+	} else if info.IsDir() {
 		if err := os.RemoveAll(targetPath); err != nil {
 			return ReturnValue{Error: fmt.Sprintf("failed to delete folder: %v", err)}
 		}
@@ -394,13 +504,11 @@ func (a *App) DeletePath(targetPath string) ReturnValue {
 
 	// Also delete any cached hurl results corresponding to the path.
 	// If a file is deleted, remove its specific cache dir; if a folder is deleted,
-	// remove the mirrored subtree under TEMP_DIR_PATH.
-	cacheRoot := tempOutputPathFor(targetPath)
-	if info.IsDir() {
-		_ = os.RemoveAll(cacheRoot)
-	} else {
+	// remove the mirrored subtree under TEMP_DIR_PATH. A trashed path keeps
+	// them for a restore, until the trash is emptied.
+	if permanent {
 		// Only .hurl files will have cached results, but removing a non-existent dir is safe
-		_ = os.RemoveAll(cacheRoot)
+		_ = os.RemoveAll(tempOutputPathFor(targetPath))
 	}
 
 	// Update explorer state when selection or current dir are impacted
@@ -417,6 +525,7 @@ func (a *App) DeletePath(targetPath string) ReturnValue {
         if stat, err := os.Stat(parent); err == nil && stat.IsDir() {
             if fi, err := createFileInfo(parent); err == nil {
                 a.explorerState.CurrentDir = fi
+                a.syncWatchers()
                 a.preferences.LastOpenedDir = fi.Path
                 if err := a.savePreferences(); err != nil {
                     fmt.Printf("failed to save preferences after delete: %v\n", err)
@@ -425,5 +534,5 @@ func (a *App) DeletePath(targetPath string) ReturnValue {
         }
     }
 
-	return ReturnValue{FileExplorer: a.explorerState}
+	return ReturnValue{FileExplorer: a.explorerState, Transfers: transfers}
 }
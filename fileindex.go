@@ -0,0 +1,482 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fileIndex lists the files under the root of the active workspace and keeps
+// the list current without walking the whole tree again: every indexed folder
+// is watched for OS notifications, and a refresh only reads the folders and
+// files they name. When notifications are not available, a refresh reads the
+// folders whose modification time changed, which adding, removing or renaming
+// an entry does, and stats the files. Hidden and git-ignored entries are left
+// out, and the index stops at maxIndexedFiles, maxIndexedDirs and
+// maxIndexDepth.
+
+const (
+	fileIndexInterval = time.Second
+	maxIndexedFiles   = 100000
+	maxIndexedDirs    = 10000
+	maxIndexDepth     = 16
+)
+
+type fileIndex struct {
+	mu     sync.RWMutex
+	root   string
+	built  bool
+	dirs   map[string]os.FileInfo
+	files  map[string]os.FileInfo
+	ignore *gitignore
+	// limited is set when entries were left out because of the limits.
+	limited bool
+
+	// watcher notifies changes of the indexed folders, nil when polling.
+	// dirtyDirs and dirtyFiles are the paths notified since the last refresh,
+	// and rescanAll asks for a full poll after lost notifications.
+	watcher    *fsnotify.Watcher
+	dirtyDirs  map[string]bool
+	dirtyFiles map[string]bool
+	rescanAll  bool
+
+	// pending collects the changes found since the last takeChanges,
+	// pendingAt is when the first of them was found and changedAt the last.
+	pending   []indexChange
+	pendingAt time.Time
+	changedAt time.Time
+}
+
+const (
+	changeAdded    = "add"
+	changeRemoved  = "remove"
+	changeRenamed  = "rename"
+	changeModified = "modify"
+)
+
+// indexChange is a file or folder added, removed or modified. Info is the
+// last known state of the entry, also for a removed one.
+type indexChange struct {
+	Kind string
+	Path string
+	Info os.FileInfo
+}
+
+// setRoot indexes root from now on, dropping the index of the previous root.
+// An empty root indexes nothing.
+func (x *fileIndex) setRoot(root string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if root == x.root {
+		return
+	}
+	x.root = root
+	x.built = false
+	x.dirs = nil
+	x.files = nil
+	x.limited = false
+	x.pending = nil
+	x.stopWatching()
+}
+
+// stopWatching closes the watcher, which ends its goroutine.
+func (x *fileIndex) stopWatching() {
+	if x.watcher != nil {
+		x.watcher.Close()
+		x.watcher = nil
+	}
+	x.dirtyDirs = nil
+	x.dirtyFiles = nil
+	x.rescanAll = false
+}
+
+// watch marks the paths notified by w dirty until w is closed.
+func (x *fileIndex) watch(w *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			x.mu.Lock()
+			if x.watcher == w {
+				x.notified(event.Name)
+			}
+			x.mu.Unlock()
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			x.mu.Lock()
+			if x.watcher == w {
+				if errors.Is(err, fsnotify.ErrEventOverflow) {
+					x.rescanAll = true
+				} else {
+					fmt.Printf("file watcher error: %v\n", err)
+				}
+			}
+			x.mu.Unlock()
+		}
+	}
+}
+
+// notified marks what a notification about path may have changed: the folder
+// holding path, path itself when it is an indexed folder or file.
+func (x *fileIndex) notified(path string) {
+	if _, ok := x.dirs[filepath.Dir(path)]; ok {
+		x.dirtyDirs[filepath.Dir(path)] = true
+	}
+	if _, ok := x.dirs[path]; ok {
+		x.dirtyDirs[path] = true
+	}
+	if _, ok := x.files[path]; ok {
+		x.dirtyFiles[path] = true
+	}
+}
+
+// record adds a change to the pending ones. The first build records nothing.
+func (x *fileIndex) record(kind string, path string, info os.FileInfo) {
+	if !x.built {
+		return
+	}
+	now := time.Now()
+	if len(x.pending) == 0 {
+		x.pendingAt = now
+	}
+	x.pending = append(x.pending, indexChange{Kind: kind, Path: path, Info: info})
+	x.changedAt = now
+}
+
+// refresh builds the index, or brings it up to date.
+func (x *fileIndex) refresh() {
+	x.mu.RLock()
+	root, built := x.root, x.built
+	x.mu.RUnlock()
+	if root == "" {
+		return
+	}
+	if !built {
+		x.build(root)
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.watcher == nil || x.rescanAll {
+		x.rescanAll = false
+		x.pollDirs()
+		x.pollFiles()
+		return
+	}
+	dirs := make([]string, 0, len(x.dirtyDirs))
+	for dir := range x.dirtyDirs {
+		dirs = append(dirs, dir)
+	}
+	files := x.dirtyFiles
+	x.dirtyDirs = map[string]bool{}
+	x.dirtyFiles = map[string]bool{}
+	// Parents first, so a removed folder is purged before its children are visited
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if _, ok := x.dirs[dir]; !ok {
+			continue
+		}
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			x.purge(dir)
+			continue
+		}
+		x.rescan(dir, info)
+	}
+	for path := range files {
+		x.checkFile(path)
+	}
+}
+
+// build indexes root without holding the lock, so that reading a large tree
+// does not block the index, then installs the result unless the root changed
+// meanwhile. The first build records no change. Every folder is watched
+// before it is read, and the notifications queued meanwhile are handled once
+// the index is installed, so nothing created during the build is missed.
+func (x *fileIndex) build(root string) {
+	b := &fileIndex{
+		root:   root,
+		dirs:   map[string]os.FileInfo{},
+		files:  map[string]os.FileInfo{},
+		ignore: newGitignore(root),
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Printf("failed to create file watcher, polling the workspace instead: %v\n", err)
+	} else {
+		b.watcher = watcher
+	}
+	b.scan(root)
+	watcher = b.watcher
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.root != root || x.built {
+		if watcher != nil {
+			watcher.Close()
+		}
+		return
+	}
+	x.dirs, x.files, x.ignore, x.limited = b.dirs, b.files, b.ignore, b.limited
+	x.built = true
+	x.stopWatching()
+	if watcher != nil {
+		x.watcher = watcher
+		x.dirtyDirs = map[string]bool{}
+		x.dirtyFiles = map[string]bool{}
+		go x.watch(watcher)
+	}
+}
+
+// pollDirs reads the folders whose modification time changed.
+func (x *fileIndex) pollDirs() {
+	dirs := make([]string, 0, len(x.dirs))
+	for dir := range x.dirs {
+		dirs = append(dirs, dir)
+	}
+	// Parents first, so a removed folder is purged before its children are visited
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		known, ok := x.dirs[dir]
+		if !ok {
+			continue
+		}
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			x.purge(dir)
+			continue
+		}
+		if !info.ModTime().Equal(known.ModTime()) {
+			x.rescan(dir, info)
+		}
+	}
+}
+
+// pollFiles stats every indexed file and records the modified ones. Editing
+// a file does not touch its folder, so pollDirs cannot see it.
+func (x *fileIndex) pollFiles() {
+	for path := range x.files {
+		x.checkFile(path)
+	}
+}
+
+// checkFile records a modification of an indexed file. A removed file is
+// seen through its folder.
+func (x *fileIndex) checkFile(path string) {
+	known, ok := x.files[path]
+	if !ok {
+		return
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return
+	}
+	if !info.ModTime().Equal(known.ModTime()) || info.Size() != known.Size() {
+		x.files[path] = info
+		x.record(changeModified, path, info)
+	}
+}
+
+func (x *fileIndex) visible(path string, isDir bool) bool {
+	if strings.HasPrefix(filepath.Base(path), ".") {
+		return false
+	}
+	return !x.ignore.ignored(path, isDir)
+}
+
+// depth returns how many folders dir is below the root.
+func (x *fileIndex) depth(dir string) int {
+	rel, err := filepath.Rel(x.root, dir)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+// scan walks dir and everything below it, recording what it finds, and
+// watches the new folders before reading them.
+func (x *fileIndex) scan(dir string) {
+	if len(x.dirs) >= maxIndexedDirs || x.depth(dir) > maxIndexDepth {
+		x.limited = true
+		return
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return
+	}
+	if x.watcher != nil {
+		if err := x.watcher.Add(dir); err != nil {
+			// Usually the limit of watches of the OS: poll instead
+			fmt.Printf("failed to watch %s, polling the workspace instead: %v\n", dir, err)
+			x.stopWatching()
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	x.dirs[dir] = info
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !x.visible(path, entry.IsDir()) {
+			continue
+		}
+		if entry.IsDir() {
+			if sub, err := entry.Info(); err == nil {
+				x.record(changeAdded, path, sub)
+			}
+			x.scan(path)
+			continue
+		}
+		x.addFile(path, entry)
+	}
+}
+
+func (x *fileIndex) addFile(path string, entry os.DirEntry) {
+	if len(x.files) >= maxIndexedFiles {
+		x.limited = true
+		return
+	}
+	info, err := entry.Info()
+	if err != nil {
+		return
+	}
+	x.files[path] = info
+	x.record(changeAdded, path, info)
+}
+
+// rescan compares the entries of dir with the index.
+func (x *fileIndex) rescan(dir string, info os.FileInfo) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		x.purge(dir)
+		return
+	}
+	x.dirs[dir] = info
+	// A new or renamed .gitignore changes what is visible below dir
+	x.ignore.forget(dir)
+
+	present := map[string]bool{}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if x.visible(path, entry.IsDir()) {
+			present[path] = true
+		}
+	}
+
+	// Removed entries go first: a folder renamed within dir keeps its watch,
+	// which is dropped with the old path and must be added again for the new.
+	for path, file := range x.files {
+		if filepath.Dir(path) == dir && !present[path] {
+			delete(x.files, path)
+			x.record(changeRemoved, path, file)
+		}
+	}
+	for sub := range x.dirs {
+		if filepath.Dir(sub) == dir && !present[sub] {
+			x.purge(sub)
+		}
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !present[path] {
+			continue
+		}
+		if entry.IsDir() {
+			if _, known := x.dirs[path]; !known {
+				if sub, err := entry.Info(); err == nil {
+					x.record(changeAdded, path, sub)
+				}
+				x.scan(path)
+			}
+		} else if _, known := x.files[path]; !known {
+			x.addFile(path, entry)
+		}
+	}
+}
+
+// purge forgets dir and everything below it.
+func (x *fileIndex) purge(dir string) {
+	prefix := dir + string(filepath.Separator)
+	for sub, info := range x.dirs {
+		if sub == dir || strings.HasPrefix(sub, prefix) {
+			delete(x.dirs, sub)
+			if x.watcher != nil {
+				// Fails for a removed folder, whose watch is already gone
+				_ = x.watcher.Remove(sub)
+			}
+			if sub != x.root {
+				x.record(changeRemoved, sub, info)
+			}
+		}
+	}
+	for path, file := range x.files {
+		if strings.HasPrefix(path, prefix) {
+			delete(x.files, path)
+			x.record(changeRemoved, path, file)
+		}
+	}
+}
+
+// takeChanges returns the pending changes once none was found for quiet, or
+// once the oldest waited for maxDelay, so that a burst of changes such as a
+// git pull is reported at once.
+func (x *fileIndex) takeChanges(quiet time.Duration, maxDelay time.Duration) (string, []indexChange) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if len(x.pending) == 0 {
+		return x.root, nil
+	}
+	if time.Since(x.changedAt) < quiet && time.Since(x.pendingAt) < maxDelay {
+		return x.root, nil
+	}
+	changes := x.pending
+	x.pending = nil
+	return x.root, changes
+}
+
+// find returns the indexed file that is the same file as info, if any.
+func (x *fileIndex) find(info os.FileInfo) string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	for path, file := range x.files {
+		if os.SameFile(file, info) {
+			return path
+		}
+	}
+	return ""
+}
+
+// isLimited reports whether entries were left out because of the limits.
+func (x *fileIndex) isLimited() bool {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.limited
+}
+
+// list brings the index up to date and returns the indexed files.
+func (x *fileIndex) list() (string, []string) {
+	x.refresh()
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	files := make([]string, 0, len(x.files))
+	for path := range x.files {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return x.root, files
+}
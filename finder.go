@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Scores of the fuzzy matcher. Every query character must appear in order in
+// the path relative to the workspace; matches at the start of a word, in the
+// file name and next to each other score more, gaps between them less.
+const (
+	fuzzyCharScore   = 1
+	fuzzyBoundary    = 8
+	fuzzyBaseName    = 4
+	fuzzyConsecutive = 5
+	fuzzyGap         = 1
+	fuzzyExactName   = 20
+	fuzzyHurlFile    = 3
+	// Bonus of the most recently opened file, decreasing with older files
+	fuzzyRecent = 30
+
+	defaultFindLimit = 50
+)
+
+// FileMatch is a result of FindFiles. Positions are the indexes, in runes,
+// of the matched characters of RelPath, for highlighting.
+type FileMatch struct {
+	Path      string `json:"path"`
+	RelPath   string `json:"relPath"`
+	Name      string `json:"name"`
+	Score     int    `json:"score"`
+	Positions []int  `json:"positions"`
+	Recent    bool   `json:"recent,omitempty"`
+}
+
+func isWordBoundary(runes []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	switch runes[i-1] {
+	case '/', '\\', '_', '-', '.', ' ':
+		return true
+	}
+	return unicode.IsLower(runes[i-1]) && unicode.IsUpper(runes[i])
+}
+
+// fuzzyMatch scores candidate against query and returns the matched positions,
+// or false when the query characters do not all appear in order.
+func fuzzyMatch(query []rune, candidate string) (int, []int, bool) {
+	runes := []rune(candidate)
+	n, m := len(query), len(runes)
+	if n == 0 {
+		return 0, nil, true
+	}
+	if n > m {
+		return 0, nil, false
+	}
+	lower := make([]rune, m)
+	for j, r := range runes {
+		lower[j] = unicode.ToLower(r)
+	}
+	baseStart := strings.LastIndexAny(candidate, `/\`) + 1
+	baseStart = len([]rune(candidate[:baseStart]))
+
+	const none = math.MinInt / 2
+	// score[i][j] is the best score with query[i] matched at j, from[i][j]
+	// the position of query[i-1] in that match
+	score := make([][]int, n)
+	from := make([][]int, n)
+	for i := range score {
+		score[i] = make([]int, m)
+		from[i] = make([]int, m)
+	}
+	for i := 0; i < n; i++ {
+		// best of score[i-1][k] - gaps for k < j-1, and where it came from
+		gapBest, gapFrom := none, -1
+		for j := 0; j < m; j++ {
+			if i > 0 && j >= 2 {
+				if gapBest != none {
+					gapBest -= fuzzyGap
+				}
+				if candidate := score[i-1][j-2] - fuzzyGap; candidate > gapBest {
+					gapBest, gapFrom = candidate, j-2
+				}
+			}
+			score[i][j], from[i][j] = none, -1
+			if lower[j] != query[i] {
+				continue
+			}
+			s := fuzzyCharScore
+			if isWordBoundary(runes, j) {
+				s += fuzzyBoundary
+			}
+			if j >= baseStart {
+				s += fuzzyBaseName
+			}
+			if i == 0 {
+				score[i][j] = s
+				continue
+			}
+			best, prev := gapBest, gapFrom
+			if j >= 1 && score[i-1][j-1] != none && score[i-1][j-1]+fuzzyConsecutive > best {
+				best, prev = score[i-1][j-1]+fuzzyConsecutive, j-1
+			}
+			if best != none {
+				score[i][j], from[i][j] = best+s, prev
+			}
+		}
+	}
+
+	bestScore, bestEnd := none, -1
+	for j := 0; j < m; j++ {
+		if score[n-1][j] > bestScore {
+			bestScore, bestEnd = score[n-1][j], j
+		}
+	}
+	if bestEnd < 0 {
+		return 0, nil, false
+	}
+	positions := make([]int, n)
+	for i, j := n-1, bestEnd; i >= 0; i-- {
+		positions[i] = j
+		j = from[i][j]
+	}
+	return bestScore, positions, true
+}
+
+// recentRanks maps the recently opened files to their rank, most recent first.
+func (a *App) recentRanks() map[string]int {
+	recent := a.preferences.RecentFiles
+	if ws := a.activeWorkspace(); ws != nil {
+		recent = ws.RecentFiles
+	}
+	ranks := make(map[string]int, len(recent))
+	for i, path := range recent {
+		ranks[path] = i
+	}
+	return ranks
+}
+
+// FindFiles returns the files of the workspace matching query, best first.
+// An empty query lists the recently opened files.
+func (a *App) FindFiles(query string, limit int) ReturnValue {
+	if limit <= 0 {
+		limit = defaultFindLimit
+	}
+	a.fileIndex.setRoot(a.indexRoot())
+	root, files := a.fileIndex.list()
+	if root == "" {
+		return ReturnValue{Error: "no workspace to search, open a folder as a workspace"}
+	}
+	ranks := a.recentRanks()
+
+	var queryRunes []rune
+	for _, r := range strings.ToLower(query) {
+		if !unicode.IsSpace(r) {
+			queryRunes = append(queryRunes, r)
+		}
+	}
+	queryName := strings.ToLower(strings.TrimSpace(query))
+
+	matches := []FileMatch{}
+	for _, path := range files {
+		rank, recent := ranks[path]
+		if len(queryRunes) == 0 && !recent {
+			continue
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		score, positions, ok := fuzzyMatch(queryRunes, rel)
+		if !ok {
+			continue
+		}
+		name := filepath.Base(path)
+		if strings.TrimSuffix(strings.ToLower(name), strings.ToLower(filepath.Ext(name))) == queryName ||
+			strings.ToLower(name) == queryName {
+			score += fuzzyExactName
+		}
+		if isHurlFile(name) {
+			score += fuzzyHurlFile
+		}
+		if recent {
+			score += fuzzyRecent * (maxRecentFiles - rank) / maxRecentFiles
+		}
+		matches = append(matches, FileMatch{
+			Path:      path,
+			RelPath:   rel,
+			Name:      name,
+			Score:     score,
+			Positions: positions,
+			Recent:    recent,
+		})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		// Shorter paths first, they are usually what was meant
+		if len(matches[i].RelPath) != len(matches[j].RelPath) {
+			return len(matches[i].RelPath) < len(matches[j].RelPath)
+		}
+		return matches[i].RelPath < matches[j].RelPath
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return ReturnValue{Matches: matches}
+}
+
+// RebuildFileIndex scans the workspace again from scratch.
+func (a *App) RebuildFileIndex() ReturnValue {
+	a.fileIndex.setRoot("")
+	a.fileIndex.setRoot(a.indexRoot())
+	if root, _ := a.fileIndex.list(); root == "" {
+		return ReturnValue{Error: "no workspace to index"}
+	}
+	if a.fileIndex.isLimited() {
+		return ReturnValue{Error: fmt.Sprintf("the workspace has more than %d files, %d folders or %d levels, some are not indexed",
+			maxIndexedFiles, maxIndexedDirs, maxIndexDepth)}
+	}
+	return ReturnValue{}
+}
@@ -7,6 +7,8 @@ export function ChangeDirectory(arg1:string):Promise<main.ReturnValue>;
 
 export function ClearSelection():Promise<void>;
 
+export function CloseWorkspace(arg1:string):Promise<main.ReturnValue>;
+
 export function CreateFolder(arg1:string):Promise<main.ReturnValue>;
 
 export function CreateNewFile(arg1:string,arg2:string):Promise<main.ReturnValue>;
@@ -15,6 +17,8 @@ export function DeletePath(arg1:string):Promise<main.ReturnValue>;
 
 export function ExecuteHurl(arg1:string,arg2:string):Promise<main.ReturnValue>;
 
+export function ForgetWorkspace(arg1:string):Promise<main.ReturnValue>;
+
 export function GetCurrentDirectory():Promise<main.FileInfo>;
 
 export function GetEnvFilePath():Promise<main.ReturnValue>;
@@ -31,12 +35,20 @@ export function GetHurlResult(arg1:string):Promise<main.ReturnValue>;
 
 export function GetSelectedFile():Promise<main.FileInfo>;
 
+export function GetWorkspaces():Promise<main.ReturnValue>;
+
 export function NavigateUp():Promise<main.ReturnValue>;
 
+export function OpenWorkspace(arg1:string):Promise<main.ReturnValue>;
+
 export function RenamePath(arg1:string,arg2:string):Promise<main.ReturnValue>;
 
+export function ReopenWorkspace(arg1:string):Promise<main.ReturnValue>;
+
 export function SelectFile(arg1:string):Promise<main.ReturnValue>;
 
 export function SetCurrentFile(arg1:context.Context,arg2:main.FileInfo):Promise<void>;
 
+export function SwitchWorkspace(arg1:string):Promise<main.ReturnValue>;
+
 export function WriteToSelectedFile(arg1:string):Promise<main.ReturnValue>;
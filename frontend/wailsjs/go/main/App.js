@@ -10,6 +10,10 @@ export function ClearSelection() {
   return window['go']['main']['App']['ClearSelection']();
 }
 
+export function CloseWorkspace(arg1) {
+  return window['go']['main']['App']['CloseWorkspace'](arg1);
+}
+
 export function CreateFolder(arg1) {
   return window['go']['main']['App']['CreateFolder'](arg1);
 }
@@ -26,6 +30,10 @@ export function ExecuteHurl(arg1, arg2) {
   return window['go']['main']['App']['ExecuteHurl'](arg1, arg2);
 }
 
+export function ForgetWorkspace(arg1) {
+  return window['go']['main']['App']['ForgetWorkspace'](arg1);
+}
+
 export function GetCurrentDirectory() {
   return window['go']['main']['App']['GetCurrentDirectory']();
 }
@@ -58,14 +66,26 @@ export function GetSelectedFile() {
   return window['go']['main']['App']['GetSelectedFile']();
 }
 
+export function GetWorkspaces() {
+  return window['go']['main']['App']['GetWorkspaces']();
+}
+
 export function NavigateUp() {
   return window['go']['main']['App']['NavigateUp']();
 }
 
+export function OpenWorkspace(arg1) {
+  return window['go']['main']['App']['OpenWorkspace'](arg1);
+}
+
 export function RenamePath(arg1, arg2) {
   return window['go']['main']['App']['RenamePath'](arg1, arg2);
 }
 
+export function ReopenWorkspace(arg1) {
+  return window['go']['main']['App']['ReopenWorkspace'](arg1);
+}
+
 export function SelectFile(arg1) {
   return window['go']['main']['App']['SelectFile'](arg1);
 }
@@ -74,6 +94,10 @@ export function SetCurrentFile(arg1, arg2) {
   return window['go']['main']['App']['SetCurrentFile'](arg1, arg2);
 }
 
+export function SwitchWorkspace(arg1) {
+  return window['go']['main']['App']['SwitchWorkspace'](arg1);
+}
+
 export function WriteToSelectedFile(arg1) {
   return window['go']['main']['App']['WriteToSelectedFile'](arg1);
 }
@@ -356,6 +356,96 @@ export namespace main {
 		}
 	}
 	
+	export class WorkspaceSettings {
+	    allowShellVariables: boolean;
+	    allowHooks: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new WorkspaceSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.allowShellVariables = source["allowShellVariables"];
+	        this.allowHooks = source["allowHooks"];
+	    }
+	}
+	export class Workspace {
+	    name: string;
+	    root: string;
+	    lastOpenedFile?: string;
+	    lastOpenedDir?: string;
+	    lastEnv?: string;
+	    recentFiles?: string[];
+	    settings: WorkspaceSettings;
+	
+	    static createFrom(source: any = {}) {
+	        return new Workspace(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.root = source["root"];
+	        this.lastOpenedFile = source["lastOpenedFile"];
+	        this.lastOpenedDir = source["lastOpenedDir"];
+	        this.lastEnv = source["lastEnv"];
+	        this.recentFiles = source["recentFiles"];
+	        this.settings = this.convertValues(source["settings"], WorkspaceSettings);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class WorkspaceList {
+	    active: string;
+	    open: Workspace[];
+	    recent: Workspace[];
+	
+	    static createFrom(source: any = {}) {
+	        return new WorkspaceList(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.active = source["active"];
+	        this.open = this.convertValues(source["open"], Workspace);
+	        this.recent = this.convertValues(source["recent"], Workspace);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class ReturnValue {
 	    fileContent?: string;
 	    fileExplorer: FileExplorerState;
@@ -364,6 +454,9 @@ export namespace main {
 	    hurlReport?: HurlSession[];
 	    envs?: string[];
 	    envFilePath?: string;
+	    workspaces?: WorkspaceList;
+	    workspace?: Workspace;
+	    errorCode?: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new ReturnValue(source);
@@ -378,6 +471,9 @@ export namespace main {
 	        this.hurlReport = this.convertValues(source["hurlReport"], HurlSession);
 	        this.envs = source["envs"];
 	        this.envFilePath = source["envFilePath"];
+	        this.workspaces = this.convertValues(source["workspaces"], WorkspaceList);
+	        this.workspace = this.convertValues(source["workspace"], Workspace);
+	        this.errorCode = source["errorCode"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes data to a temp file next to path and renames it over
+// path, so readers never see a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmpPath, err := writeTempSibling(path, data, perm)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+func writeTempSibling(path string, data []byte, perm os.FileMode) (string, error) {
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file for %s: %w", path, err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to write temp file for %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to sync temp file for %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to close temp file for %s: %w", path, err)
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to set permissions for %s: %w", path, err)
+	}
+	return tmpPath, nil
+}
+
+// writeFilesAtomic replaces several files as one operation: every new content is
+// written to a temp file first, and if replacing one of them fails the files
+// already replaced are restored to their previous content.
+func writeFilesAtomic(files map[string][]byte, perm os.FileMode) error {
+	temps := map[string]string{}
+	cleanup := func() {
+		for _, tmpPath := range temps {
+			os.Remove(tmpPath)
+		}
+	}
+
+	originals := map[string][]byte{}
+	for path, data := range files {
+		if original, err := os.ReadFile(path); err == nil {
+			originals[path] = original
+		}
+		tmpPath, err := writeTempSibling(path, data, perm)
+		if err != nil {
+			cleanup()
+			return err
+		}
+		temps[path] = tmpPath
+	}
+
+	var replaced []string
+	for path, tmpPath := range temps {
+		if err := os.Rename(tmpPath, path); err != nil {
+			cleanup()
+			for _, done := range replaced {
+				if original, ok := originals[done]; ok {
+					_ = writeFileAtomic(done, original, perm)
+				} else {
+					os.Remove(done)
+				}
+			}
+			return fmt.Errorf("failed to replace %s: %w", path, err)
+		}
+		delete(temps, path)
+		replaced = append(replaced, path)
+	}
+	return nil
+}
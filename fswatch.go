@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// The workspace is watched through the file index, which follows OS
+// notifications and falls back to polling when they are not available.
+// Changes are reported once the tree has been quiet for a while, so a git
+// pull or a build arrives as one event instead of hundreds.
+
+const (
+	// EventFilesChanged carries a FilesChangedEvent after files of the
+	// workspace were added, removed, renamed or modified.
+	EventFilesChanged = "files:changed"
+	// EventSelectedFileChanged carries a SelectedFileEvent when the selected
+	// file was modified, removed or renamed outside the app.
+	EventSelectedFileChanged = "file:changed"
+)
+
+const (
+	fileChangeQuiet    = 500 * time.Millisecond
+	fileChangeMaxDelay = 5 * time.Second
+)
+
+// FileChange is a change of the workspace. Type is "add", "remove", "rename"
+// or "modify"; OldPath is set for a rename and File for everything but a
+// removal. The changes below an added, removed or renamed folder are left out.
+type FileChange struct {
+	Type    string    `json:"type"`
+	Path    string    `json:"path"`
+	OldPath string    `json:"oldPath,omitempty"`
+	IsDir   bool      `json:"isDir"`
+	File    *FileInfo `json:"file,omitempty"`
+}
+
+type FilesChangedEvent struct {
+	Root    string       `json:"root"`
+	Changes []FileChange `json:"changes"`
+}
+
+// SelectedFileEvent reports a change of the selected file. Type is "modify",
+// "remove" or "rename", NewPath is set for a rename.
+type SelectedFileEvent struct {
+	Path    string    `json:"path"`
+	Type    string    `json:"type"`
+	NewPath string    `json:"newPath,omitempty"`
+	File    *FileInfo `json:"file,omitempty"`
+}
+
+func fileInfoOf(path string, info os.FileInfo) *FileInfo {
+	return &FileInfo{
+		Name:     filepath.Base(path),
+		Path:     path,
+		IsDir:    info.IsDir(),
+		Size:     info.Size(),
+		Modified: info.ModTime().Format("2006-01-02 15:04:05"),
+	}
+}
+
+// netChanges reduces the changes of each path to one: a file added then
+// removed did not change, one removed then added again was modified.
+func netChanges(changes []indexChange) []indexChange {
+	type history struct {
+		first   string
+		last    indexChange
+		removed os.FileInfo
+	}
+	histories := map[string]*history{}
+	var order []string
+	for _, change := range changes {
+		h, ok := histories[change.Path]
+		if !ok {
+			h = &history{first: change.Kind}
+			histories[change.Path] = h
+			order = append(order, change.Path)
+		}
+		h.last = change
+		if change.Kind == changeRemoved && h.removed == nil {
+			h.removed = change.Info
+		}
+	}
+
+	var net []indexChange
+	for _, path := range order {
+		h := histories[path]
+		change := h.last
+		switch {
+		case h.first == changeAdded && change.Kind == changeRemoved:
+			continue
+		case h.first == changeAdded:
+			change.Kind = changeAdded
+		case change.Kind == changeRemoved:
+			if h.first == changeRemoved {
+				change.Info = h.removed
+			}
+		case h.first == changeRemoved:
+			if change.Info.IsDir() {
+				continue
+			}
+			change.Kind = changeModified
+		}
+		net = append(net, change)
+	}
+	return net
+}
+
+// fileChanges turns the changes of the index into events, pairing a removed
+// and an added entry that are the same file into a rename.
+func fileChanges(changes []indexChange) []FileChange {
+	type key struct {
+		size    int64
+		modTime int64
+		isDir   bool
+	}
+	keyOf := func(info os.FileInfo) key {
+		return key{size: info.Size(), modTime: info.ModTime().UnixNano(), isDir: info.IsDir()}
+	}
+
+	net := netChanges(changes)
+	added := map[key][]int{}
+	for i, change := range net {
+		if change.Kind == changeAdded {
+			added[keyOf(change.Info)] = append(added[keyOf(change.Info)], i)
+		}
+	}
+	renamedTo := map[int]string{}
+	renamed := map[int]bool{}
+	for i, change := range net {
+		if change.Kind != changeRemoved {
+			continue
+		}
+		for _, j := range added[keyOf(change.Info)] {
+			if !renamed[j] && os.SameFile(change.Info, net[j].Info) {
+				renamedTo[i] = net[j].Path
+				renamed[j] = true
+				break
+			}
+		}
+	}
+
+	var events []FileChange
+	for i, change := range net {
+		if renamed[i] {
+			continue
+		}
+		event := FileChange{Type: change.Kind, Path: change.Path, IsDir: change.Info.IsDir()}
+		if newPath, ok := renamedTo[i]; ok {
+			event.Type = changeRenamed
+			event.OldPath = change.Path
+			event.Path = newPath
+		}
+		if event.Type != changeRemoved {
+			event.File = fileInfoOf(event.Path, change.Info)
+		}
+		events = append(events, event)
+	}
+	return collapseChanges(events)
+}
+
+// collapseChanges drops the changes implied by a change of a parent folder,
+// such as the files of a removed folder.
+func collapseChanges(events []FileChange) []FileChange {
+	dirs := map[string]FileChange{}
+	for _, event := range events {
+		if event.IsDir && event.Type != changeModified {
+			dirs[event.Path] = event
+		}
+	}
+	implied := func(event FileChange) bool {
+		for dir := filepath.Dir(event.Path); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			parent, ok := dirs[dir]
+			if !ok || parent.Type != event.Type {
+				continue
+			}
+			if event.Type != changeRenamed {
+				return true
+			}
+			rel, err := filepath.Rel(parent.Path, event.Path)
+			if err == nil && event.OldPath == filepath.Join(parent.OldPath, rel) {
+				return true
+			}
+		}
+		return false
+	}
+
+	kept := []FileChange{}
+	for _, event := range events {
+		if !implied(event) {
+			kept = append(kept, event)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].Path < kept[j].Path
+	})
+	return kept
+}
+
+// selectedWatcher notices changes of the selected file, which may be outside
+// the workspace or hidden from the index. Only path is shared with the
+// bindings, the rest belongs to the polling goroutine.
+type selectedWatcher struct {
+	mu   sync.Mutex
+	path string
+
+	watched  string
+	reported os.FileInfo
+	seen     os.FileInfo
+}
+
+// setPath sets the file to watch, none when path is empty.
+func (w *selectedWatcher) setPath(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.path = path
+}
+
+func sameState(a os.FileInfo, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
+
+// poll reports a change of the selected file once it has been stable for a
+// poll, so that an editor saving by removing and writing the file is seen as
+// a modification. A removed file found elsewhere in the index was renamed.
+func (w *selectedWatcher) poll(index *fileIndex) (SelectedFileEvent, bool) {
+	w.mu.Lock()
+	path := w.path
+	w.mu.Unlock()
+
+	var current os.FileInfo
+	if path != "" {
+		if info, err := os.Stat(path); err == nil {
+			current = info
+		}
+	}
+	if path != w.watched {
+		w.watched = path
+		w.reported = current
+		w.seen = current
+		return SelectedFileEvent{}, false
+	}
+	if path == "" || !sameState(current, w.seen) {
+		w.seen = current
+		return SelectedFileEvent{}, false
+	}
+	if sameState(current, w.reported) {
+		return SelectedFileEvent{}, false
+	}
+
+	previous := w.reported
+	w.reported = current
+	event := SelectedFileEvent{Path: path, Type: changeModified}
+	switch {
+	case current != nil:
+		event.File = fileInfoOf(path, current)
+	case previous != nil:
+		event.Type = changeRemoved
+		if newPath := index.find(previous); newPath != "" && newPath != path {
+			event.Type = changeRenamed
+			event.NewPath = newPath
+			if info, err := os.Stat(newPath); err == nil {
+				event.File = fileInfoOf(newPath, info)
+			}
+		}
+	}
+	return event, true
+}
+
+// watchFiles keeps the file index current until ctx is done, emitting
+// EventFilesChanged and EventSelectedFileChanged.
+func (a *App) watchFiles(ctx context.Context) {
+	ticker := time.NewTicker(fileIndexInterval)
+	defer ticker.Stop()
+
+	a.selectedWatch.poll(&a.fileIndex)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.fileIndex.refresh()
+			if root, changes := a.fileIndex.takeChanges(fileChangeQuiet, fileChangeMaxDelay); len(changes) > 0 {
+				if events := fileChanges(changes); len(events) > 0 {
+					runtime.EventsEmit(ctx, EventFilesChanged, FilesChangedEvent{Root: root, Changes: events})
+				}
+			}
+			if event, changed := a.selectedWatch.poll(&a.fileIndex); changed {
+				runtime.EventsEmit(ctx, EventSelectedFileChanged, event)
+			}
+		}
+	}
+}
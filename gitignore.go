@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitignore matches paths against the .gitignore files of a repository. It
+// supports the common syntax: # comments, ! negation, a trailing / for
+// directories only, patterns anchored by a / and ** across folders.
+
+type ignoreRule struct {
+	base     string // folder of the .gitignore file
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+type gitignore struct {
+	// root is the top of the repository, or the folder walked when there is none.
+	root string
+	// rules caches the rules applying inside each folder, inherited ones included.
+	rules map[string][]ignoreRule
+}
+
+// findGitRoot returns the closest parent of dir holding a .git entry, or "".
+func findGitRoot(dir string) string {
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, ".git")); err == nil {
+			return d
+		}
+		if filepath.Dir(d) == d {
+			return ""
+		}
+	}
+}
+
+// newGitignore returns a matcher for the files under root, reading the
+// .gitignore files from the top of the repository down.
+func newGitignore(root string) *gitignore {
+	top := findGitRoot(root)
+	if top == "" {
+		top = root
+	}
+	return &gitignore{root: top, rules: map[string][]ignoreRule{}}
+}
+
+func parseGitignore(dir string) []ignoreRule {
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: dir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		// A slash anywhere but at the end anchors the pattern to its folder
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// rulesFor returns the rules applying to the entries of dir.
+func (g *gitignore) rulesFor(dir string) []ignoreRule {
+	if rules, ok := g.rules[dir]; ok {
+		return rules
+	}
+	var rules []ignoreRule
+	if dir != g.root && isWithin(g.root, dir) {
+		rules = append(rules, g.rulesFor(filepath.Dir(dir))...)
+	}
+	rules = append(rules, parseGitignore(dir)...)
+	g.rules[dir] = rules
+	return rules
+}
+
+// ignored reports whether the entry at p is ignored. The last matching rule
+// wins, so a later ! pattern can bring a file back.
+func (g *gitignore) ignored(p string, isDir bool) bool {
+	if filepath.Base(p) == ".git" {
+		return true
+	}
+	ignored := false
+	for _, rule := range g.rulesFor(filepath.Dir(p)) {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(rule.base, p)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		var matched bool
+		if rule.anchored {
+			matched = matchGlobPath(rule.pattern, rel)
+		} else {
+			matched, _ = path.Match(rule.pattern, path.Base(rel))
+		}
+		if matched {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// matchGlobPath matches a slash separated path against a pattern where **
+// stands for any number of folders.
+func matchGlobPath(pattern string, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// forget drops the cached rules of dir and the folders below it, to read
+// their .gitignore files again.
+func (g *gitignore) forget(dir string) {
+	prefix := dir + string(filepath.Separator)
+	for cached := range g.rules {
+		if cached == dir || strings.HasPrefix(cached, prefix) {
+			delete(g.rules, cached)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Hooks are shell commands run around ExecuteHurl, declared in
+// <root>/.hurlstudio/hooks.json for the whole project and in .hurlhooks.json
+// for a folder and the folders below it:
+//
+//	{
+//	  "pre":  [{"name": "seed", "command": "./scripts/seed.sh"}],
+//	  "post": [{"name": "cleanup", "command": "./scripts/cleanup.sh", "timeout": 120}]
+//	}
+//
+// Pre hooks run from the project down to the folder of the file, post hooks
+// in the reverse order. Commands run in the folder of their hooks file with
+// HURL_FILE and HURL_ENV set, and for post hooks HURL_REPORT and
+// HURL_EXIT_CODE. Lines printed by a pre hook as NAME=VALUE become variables
+// of the run. A failing pre hook cancels the run; post hooks run whatever the
+// result. Hooks run only when enabled in preferences.
+
+const (
+	HookStagePre  = "pre"
+	HookStagePost = "post"
+)
+
+const (
+	projectHooksFileName = "hooks.json"
+	folderHooksFileName  = ".hurlhooks.json"
+	defaultHookTimeout   = 60 * time.Second
+)
+
+type HookConfig struct {
+	Name    string `json:"name,omitempty"`
+	Command string `json:"command"`
+	// Timeout in seconds, 60 by default.
+	Timeout int `json:"timeout,omitempty"`
+}
+
+type HooksFile struct {
+	Pre  []HookConfig `json:"pre,omitempty"`
+	Post []HookConfig `json:"post,omitempty"`
+}
+
+// hook is a configured command with the file declaring it.
+type hook struct {
+	HookConfig
+	Source string
+}
+
+// HookResult is the outcome of one hook, shown with the run.
+type HookResult struct {
+	Name     string `json:"name"`
+	Stage    string `json:"stage"`
+	Command  string `json:"command"`
+	Source   string `json:"source"`
+	Output   string `json:"output"`
+	ExitCode int    `json:"exitCode"`
+	Duration int64  `json:"duration"` // milliseconds
+	Error    string `json:"error,omitempty"`
+	// Variables lists the names of the variables set by a pre hook.
+	Variables []string `json:"variables,omitempty"`
+}
+
+// displayName returns the name of the hook, or its command.
+func (h hook) displayName() string {
+	if h.Name != "" {
+		return h.Name
+	}
+	return h.Command
+}
+
+// hookFilePaths returns the hooks files that may apply to filePath, found or
+// not, project first.
+func hookFilePaths(filePath string) []string {
+	dir := filepath.Dir(filePath)
+	root := findProjectRoot(dir)
+
+	var paths []string
+	if root != "" {
+		paths = append(paths, filepath.Join(root, projectConfigDirName, projectHooksFileName))
+	} else {
+		root = dir
+	}
+	var dirs []string
+	for d := dir; ; d = filepath.Dir(d) {
+		dirs = append([]string{d}, dirs...)
+		if d == root || filepath.Dir(d) == d {
+			break
+		}
+	}
+	for _, d := range dirs {
+		paths = append(paths, filepath.Join(d, folderHooksFileName))
+	}
+	return paths
+}
+
+// loadHooks returns the pre and post hooks of filePath in the order they run.
+func loadHooks(filePath string) ([]hook, []hook, error) {
+	var pre, post []hook
+	for _, path := range hookFilePaths(filePath) {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read hooks: %w", err)
+		}
+		var file HooksFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		for _, h := range file.Pre {
+			pre = append(pre, hook{HookConfig: h, Source: path})
+		}
+		// Inner folders clean up before outer ones
+		for i := len(file.Post) - 1; i >= 0; i-- {
+			post = append([]hook{{HookConfig: file.Post[i], Source: path}}, post...)
+		}
+	}
+	for _, h := range append(append([]hook{}, pre...), post...) {
+		if strings.TrimSpace(h.Command) == "" {
+			return nil, nil, fmt.Errorf("%s: a hook has no command", h.Source)
+		}
+	}
+	return pre, post, nil
+}
+
+// runHook runs a hook in the folder of its hooks file with env added to the
+// environment.
+func runHook(h hook, stage string, env []string) HookResult {
+	result := HookResult{Name: h.displayName(), Stage: stage, Command: h.Command, Source: h.Source}
+	timeout := defaultHookTimeout
+	if h.Timeout > 0 {
+		timeout = time.Duration(h.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if goruntime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", h.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", h.Command)
+	}
+	dir := filepath.Dir(h.Source)
+	if filepath.Base(dir) == projectConfigDirName {
+		dir = filepath.Dir(dir)
+	}
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	// Do not wait for background processes still holding the output
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	result.Duration = time.Since(start).Milliseconds()
+	result.Output = string(out)
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		result.ExitCode = -1
+		result.Error = fmt.Sprintf("timed out after %s", timeout)
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+		result.Error = fmt.Sprintf("exited with status %d", result.ExitCode)
+	case err != nil:
+		result.ExitCode = -1
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// hookVariables reads the NAME=VALUE lines of a pre hook output.
+func hookVariables(output string) map[string]string {
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		name, value, found := strings.Cut(strings.TrimRight(scanner.Text(), "\r"), "=")
+		if found && validVariableNameRe.MatchString(name) {
+			vars[name] = value
+		}
+	}
+	return vars
+}
+
+// runPreHooks runs the pre hooks in order and returns the variables they
+// set, later hooks overriding earlier ones. It stops at the first failure,
+// returning the variables set until then so they can still be redacted.
+func runPreHooks(hooks []hook, env []string) ([]HookResult, map[string]string, error) {
+	results := []HookResult{}
+	vars := map[string]string{}
+	for _, h := range hooks {
+		result := runHook(h, HookStagePre, env)
+		if result.Error == "" {
+			hookVars := hookVariables(result.Output)
+			for name, value := range hookVars {
+				vars[name] = value
+				result.Variables = append(result.Variables, name)
+			}
+			sort.Strings(result.Variables)
+		}
+		results = append(results, result)
+		if result.Error != "" {
+			return results, vars, fmt.Errorf("pre-run hook %q failed: %s", result.Name, result.Error)
+		}
+	}
+	return results, vars, nil
+}
+
+// runPostHooks runs every post hook, whatever the result of the others.
+func runPostHooks(hooks []hook, env []string) []HookResult {
+	results := []HookResult{}
+	for _, h := range hooks {
+		results = append(results, runHook(h, HookStagePost, env))
+	}
+	return results
+}
+
+// hookEnv returns the environment variables describing the run to hooks.
+func hookEnv(filePath string, envName string) []string {
+	return []string{"HURL_FILE=" + filePath, "HURL_ENV=" + envName}
+}
+
+// GetHooks returns the hooks that run around filePath, pre hooks first.
+func (a *App) GetHooks(filePath string) ReturnValue {
+	filePath, err := a.sandboxPath(filePath, false)
+	if err != nil {
+		return errorResult(err)
+	}
+	pre, post, err := loadHooks(filePath)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	hooks := []HookResult{}
+	for _, h := range pre {
+		hooks = append(hooks, HookResult{Name: h.displayName(), Stage: HookStagePre, Command: h.Command, Source: h.Source})
+	}
+	for _, h := range post {
+		hooks = append(hooks, HookResult{Name: h.displayName(), Stage: HookStagePost, Command: h.Command, Source: h.Source})
+	}
+	return ReturnValue{Hooks: hooks}
+}
+
+// SetAllowHooks turns pre-run and post-run hooks on or off, for the active
+// workspace if there is one.
+func (a *App) SetAllowHooks(allow bool) ReturnValue {
+	if ws := a.activeWorkspace(); ws != nil {
+		ws.Settings.AllowHooks = allow
+	} else {
+		a.preferences.AllowHooks = allow
+	}
+	if err := a.savePreferences(); err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	return ReturnValue{}
+}
+
+// redactHookResults masks secrets in the output of hooks.
+func redactHookResults(r *redactor, results []HookResult) {
+	for i := range results {
+		results[i].Output = r.text(results[i].Output)
+	}
+}
+
+// hooksDisabledError is returned when a file has hooks but they are disabled.
+func hooksDisabledError(pre []hook, post []hook) error {
+	var b bytes.Buffer
+	for _, h := range append(append([]hook{}, pre...), post...) {
+		fmt.Fprintf(&b, "\n  %s (%s)", h.Command, h.Source)
+	}
+	return fmt.Errorf("hooks are disabled, enable them in preferences to run:%s", b.String())
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Lightweight, line based reader for .hurl files.
+// It does not try to validate the grammar (hurl does that when running),
+// it only locates entries, sections and captures so the UI can navigate a file.
+// https://hurl.dev/docs/grammar.html
+
+var (
+	requestLineRe  = regexp.MustCompile(`^\s*([A-Z]+)\s+(\S.*?)\s*$`)
+	responseLineRe = regexp.MustCompile(`^\s*HTTP(/[0-9.]+)?\s+(\d{3}|\*)\s*$`)
+	sectionLineRe  = regexp.MustCompile(`^\s*\[([A-Za-z]+)\]\s*$`)
+	keyValueLineRe = regexp.MustCompile(`^\s*([A-Za-z0-9_\-.]+)\s*:\s*(.*?)\s*$`)
+	// query [filters] [not] predicate [value]
+	assertLineRe = regexp.MustCompile(`^\s*(status|version|url|redirects|ip|header|certificate|cookie|body|bytes|xpath|jsonpath|regex|variable|duration|sha256|md5)\b.*?\s(==|!=|>=|<=|>|<|startsWith|endsWith|contains|includes|matches|exists|is[A-Z][A-Za-z0-9]*)(\s|$)`)
+)
+
+var httpMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "DELETE": true,
+	"CONNECT": true, "OPTIONS": true, "TRACE": true, "PATCH": true,
+	"LINK": true, "UNLINK": true, "PURGE": true, "LOCK": true, "UNLOCK": true,
+	"PROPFIND": true, "VIEW": true,
+}
+
+// hurlCapture is a variable defined in a [Captures] section.
+type hurlCapture struct {
+	Name  string
+	Query string
+	Line  int
+}
+
+// hurlEntry is one request/response pair of a .hurl file. Lines are 1-based.
+type hurlEntry struct {
+	Index        int
+	Method       string
+	URL          string
+	StartLine    int
+	EndLine      int
+	ResponseLine int
+	Captures     []hurlCapture
+	Asserts      int
+}
+
+// hurlLine describes where a line sits inside the file.
+type hurlLine struct {
+	Entry   int    // 1-based entry index, 0 before the first entry
+	Section string // current section name, "" for headers and bodies
+	InBody  bool   // true inside a multiline ``` body
+	Comment bool
+}
+
+type hurlFile struct {
+	Lines   []string
+	Info    []hurlLine
+	Entries []hurlEntry
+}
+
+// isRequestLine reports whether the line starts a new entry and returns its
+// method and URL. Custom methods are accepted only when followed by something
+// that looks like a URL, to avoid mistaking body text for a request.
+func isRequestLine(line string) (string, string, bool) {
+	m := requestLineRe.FindStringSubmatch(line)
+	if m == nil {
+		return "", "", false
+	}
+	method, url := m[1], m[2]
+	if method == "HTTP" {
+		return "", "", false
+	}
+	if !httpMethods[method] &&
+		!strings.Contains(url, "://") &&
+		!strings.HasPrefix(url, "{{") {
+		return "", "", false
+	}
+	return method, url, true
+}
+
+func parseHurlFile(content string) *hurlFile {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	f := &hurlFile{
+		Lines: lines,
+		Info:  make([]hurlLine, len(lines)),
+	}
+
+	var current *hurlEntry
+	section := ""
+	inFence := false
+	lastContent := 0
+
+	closeEntry := func() {
+		if current == nil {
+			return
+		}
+		current.EndLine = lastContent
+		if current.EndLine < current.StartLine {
+			current.EndLine = current.StartLine
+		}
+		f.Entries = append(f.Entries, *current)
+		current = nil
+	}
+
+	for i, line := range lines {
+		lineNo := i + 1
+		trimmed := strings.TrimSpace(line)
+
+		if inFence {
+			if strings.HasPrefix(trimmed, "```") {
+				inFence = false
+			}
+			f.Info[i] = hurlLine{Entry: entryIndex(current), Section: section, InBody: true}
+			lastContent = lineNo
+			continue
+		}
+
+		if trimmed == "" {
+			f.Info[i] = hurlLine{Entry: entryIndex(current), Section: section}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "#") {
+			f.Info[i] = hurlLine{Entry: entryIndex(current), Section: section, Comment: true}
+			continue
+		}
+
+		if method, url, ok := isRequestLine(line); ok {
+			closeEntry()
+			current = &hurlEntry{
+				Index:     len(f.Entries) + 1,
+				Method:    method,
+				URL:       url,
+				StartLine: lineNo,
+			}
+			section = ""
+		} else if current != nil && responseLineRe.MatchString(line) {
+			current.ResponseLine = lineNo
+			section = ""
+		} else if m := sectionLineRe.FindStringSubmatch(line); m != nil {
+			section = m[1]
+		} else if strings.HasPrefix(trimmed, "```") {
+			// A body ends the sections
+			section = ""
+			if !strings.HasSuffix(trimmed, "```") || len(trimmed) < 6 {
+				inFence = true
+			}
+		} else if strings.ContainsAny(trimmed[:1], "{[<`") {
+			section = ""
+		} else if current != nil {
+			switch section {
+			case "Captures":
+				if m := keyValueLineRe.FindStringSubmatch(line); m != nil {
+					current.Captures = append(current.Captures, hurlCapture{Name: m[1], Query: m[2], Line: lineNo})
+				}
+			case "Asserts":
+				if assertLineRe.MatchString(line) {
+					current.Asserts++
+				}
+			}
+		}
+
+		f.Info[i] = hurlLine{Entry: entryIndex(current), Section: section, InBody: inFence}
+		lastContent = lineNo
+	}
+	closeEntry()
+
+	return f
+}
+
+func entryIndex(e *hurlEntry) int {
+	if e == nil {
+		return 0
+	}
+	return e.Index
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHurlFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []hurlEntry
+	}{
+		{
+			name:    "entries with sections",
+			content: "# login\nPOST http://{{host}}/login\n[Form]\nuser: alice\n\nHTTP 200\n[Captures]\ntoken: jsonpath \"$.token\"\n[Asserts]\nstatus == 200\njsonpath \"$.token\" not isEmpty\n\nGET http://{{host}}/me\nAuthorization: Bearer {{token}}\nHTTP *\n",
+			want: []hurlEntry{
+				{
+					Index: 1, Method: "POST", URL: "http://{{host}}/login", StartLine: 2, EndLine: 11, ResponseLine: 6,
+					Captures: []hurlCapture{{Name: "token", Query: `jsonpath "$.token"`, Line: 8}},
+					Asserts:  2,
+				},
+				{Index: 2, Method: "GET", URL: "http://{{host}}/me", StartLine: 13, EndLine: 15, ResponseLine: 15},
+			},
+		},
+		{
+			name:    "only query predicate lines are asserts",
+			content: "GET http://x\nHTTP 200\n[Asserts]\nheader \"Content-Type\" contains \"json\"\njsonpath \"$.items\" count >= 2\nduration < 1000\nnot an assert\nvariable \"id\" exists\n",
+			want:    []hurlEntry{{Index: 1, Method: "GET", URL: "http://x", StartLine: 1, EndLine: 8, ResponseLine: 2, Asserts: 4}},
+		},
+		{
+			name:    "a body ends the asserts",
+			content: "GET http://x\nHTTP 200\n[Asserts]\nstatus == 200\n{\n  \"status\": \"ok\",\n  \"list\": [1, 2]\n}\n",
+			want:    []hurlEntry{{Index: 1, Method: "GET", URL: "http://x", StartLine: 1, EndLine: 8, ResponseLine: 2, Asserts: 1}},
+		},
+		{
+			name:    "multiline body with request-like lines",
+			content: "POST http://x\n```\nGET http://not-an-entry\n[Asserts]\nstatus == 200\n```\nHTTP 201\n",
+			want:    []hurlEntry{{Index: 1, Method: "POST", URL: "http://x", StartLine: 1, EndLine: 7, ResponseLine: 7}},
+		},
+		{
+			name:    "custom methods need a url",
+			content: "QUERY http://x\nNOT a request\n",
+			want:    []hurlEntry{{Index: 1, Method: "QUERY", URL: "http://x", StartLine: 1, EndLine: 2}},
+		},
+		{
+			name:    "no entries",
+			content: "# just a comment\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseHurlFile(tt.content).Entries
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("entries =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// writeVariablesFile writes vars to a private temp file in the hurl
+// --variables-file format, so values never show up in the process arguments.
+// The format is one name=value per line: hurl splits on the first '=' so
+// values may contain '=', and trims each line, so a value with leading or
+// trailing spaces is written in double quotes, which hurl strips. The format
+// has no escapes, so values with line breaks are returned as HURL_<name>
+// environment variables instead, which hurl reads the same way and only the
+// user can see. The caller removes the file once hurl exits.
+func writeVariablesFile(vars map[string]string) (string, []string, error) {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		if !validVariableNameRe.MatchString(name) {
+			return "", nil, fmt.Errorf("invalid variable name: %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	var env []string
+	for _, name := range names {
+		value := vars[name]
+		if strings.ContainsAny(value, "\r\n") {
+			env = append(env, "HURL_"+name+"="+value)
+			continue
+		}
+		if value != strings.TrimSpace(value) {
+			value = `"` + value + `"`
+		}
+		b.WriteString(name)
+		b.WriteString("=")
+		b.WriteString(value)
+		b.WriteString("\n")
+	}
+
+	file, err := os.CreateTemp("", "hurlstudio-vars-*.env")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create variables file: %w", err)
+	}
+	path := file.Name()
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", nil, fmt.Errorf("failed to restrict variables file: %w", err)
+	}
+	if _, err := file.WriteString(b.String()); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", nil, fmt.Errorf("failed to write variables file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return "", nil, fmt.Errorf("failed to write variables file: %w", err)
+	}
+	return path, env, nil
+}
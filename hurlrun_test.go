@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteVariablesFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		vars     map[string]string
+		wantFile string
+		wantEnv  []string
+		wantErr  bool
+	}{
+		{
+			name:     "sorted lines",
+			vars:     map[string]string{"user": "alice", "host": "localhost"},
+			wantFile: "host=localhost\nuser=alice\n",
+		},
+		{
+			name:     "equals kept",
+			vars:     map[string]string{"query": "a=1&b=2"},
+			wantFile: "query=a=1&b=2\n",
+		},
+		{
+			name:     "spaces quoted",
+			vars:     map[string]string{"name": " padded "},
+			wantFile: "name=\" padded \"\n",
+		},
+		{
+			name:     "line breaks through the environment",
+			vars:     map[string]string{"body": "{\n  \"a\": 1\r\n}", "host": "localhost"},
+			wantFile: "host=localhost\n",
+			wantEnv:  []string{"HURL_body={\n  \"a\": 1\r\n}"},
+		},
+		{
+			name:    "invalid name",
+			vars:    map[string]string{"a b": "1"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, env, err := writeVariablesFile(tt.vars)
+			if tt.wantErr {
+				if err == nil {
+					os.Remove(path)
+					t.Fatal("writeVariablesFile succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("writeVariablesFile: %v", err)
+			}
+			defer os.Remove(path)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.wantFile {
+				t.Errorf("file = %q, want %q", data, tt.wantFile)
+			}
+			if strings.Join(env, "|") != strings.Join(tt.wantEnv, "|") {
+				t.Errorf("env = %q, want %q", env, tt.wantEnv)
+			}
+			if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 {
+				t.Errorf("mode = %o, want 600", info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestExecuteHurlMultilineVariable(t *testing.T) {
+	a, ws := newTrashApp(t)
+	// A fake hurl printing what it was given, then failing so the output is returned
+	bin := t.TempDir()
+	script := "#!/bin/sh\ncat \"$4\"\nprintf 'body=%s\\n' \"$HURL_body\"\nexit 3\n"
+	if err := os.WriteFile(filepath.Join(bin, "hurl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("HURL_body", "")
+
+	err := a.saveEnvConfig(&EnvConfig{Global: map[string]EnvValue{
+		"host": {Value: "localhost"},
+		"body": {Value: "line 1\nline 2"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(ws, "post.hurl")
+	os.WriteFile(path, []byte("POST http://{{host}}\n```\n{{body}}\n```\n"), 0644)
+	a.explorerState.SelectedFile = FileInfo{Name: "post.hurl", Path: path}
+
+	result := a.ExecuteHurl(path, "")
+	if !strings.Contains(result.Error, "exit status 3") {
+		t.Fatalf("ExecuteHurl = %+v, want the fake hurl to fail", result)
+	}
+	if !strings.Contains(result.Error, "host=localhost\n") || !strings.Contains(result.Error, "body=line 1\nline 2\n") {
+		t.Errorf("hurl got:\n%s", result.Error)
+	}
+}
@@ -26,6 +26,7 @@ func main() {
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
 		OnStartup:        app.startup,
+		OnShutdown:       app.shutdown,
 		// Enable native macOS fullscreen via the green traffic-light button
 		Mac: &mac.Options{
 			TitleBar: mac.TitleBarDefault(),
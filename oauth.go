@@ -0,0 +1,491 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// An environment can get its bearer token from an OAuth2 token endpoint. The
+// token is fetched before the run, cached until it expires and passed to hurl
+// as a variable, access_token unless configured otherwise. Any field of the
+// configuration can use {{name}} to read a variable, so the client secret or
+// the password can stay in the secret vault:
+//
+//	"oauth2": {
+//	  "staging": {
+//	    "grantType": "client_credentials",
+//	    "tokenUrl": "https://auth.example.com/oauth/token",
+//	    "clientId": "hurl-studio",
+//	    "clientSecret": "{{client_secret}}",
+//	    "scopes": ["read", "write"]
+//	  }
+//	}
+//
+// The cache database lives in the temp folder, so cached tokens are encrypted
+// with a random key kept in the config folder, readable by the user only.
+
+const (
+	OAuth2ClientCredentials = "client_credentials"
+	OAuth2Password          = "password"
+)
+
+const (
+	// OAuth2AuthBody sends the client credentials in the form, the default.
+	OAuth2AuthBody = "body"
+	// OAuth2AuthBasic sends them in an HTTP basic Authorization header.
+	OAuth2AuthBasic = "basic"
+)
+
+const (
+	defaultTokenVariable = "access_token"
+	oauthTokenBucket     = "oauth_tokens"
+	oauthTokenKeyFile    = "oauth-token.key"
+	// Tokens are renewed a little before they expire so they do not expire mid-run
+	tokenExpirySkew = 30 * time.Second
+	// Lifetime of tokens whose response has no expires_in
+	defaultTokenLifetime = 5 * time.Minute
+)
+
+var oauthHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+type OAuth2Config struct {
+	GrantType    string   `json:"grantType"`
+	TokenURL     string   `json:"tokenUrl"`
+	ClientID     string   `json:"clientId"`
+	ClientSecret string   `json:"clientSecret,omitempty"`
+	ClientAuth   string   `json:"clientAuth,omitempty"`
+	Username     string   `json:"username,omitempty"`
+	Password     string   `json:"password,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	// Variable receives the access token, access_token by default.
+	Variable string `json:"variable,omitempty"`
+}
+
+// OAuth2TokenInfo describes the token of an environment, without its value.
+type OAuth2TokenInfo struct {
+	Variable  string    `json:"variable"`
+	TokenType string    `json:"tokenType,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Cached    bool      `json:"cached"`
+}
+
+type oauthToken struct {
+	AccessToken  string    `json:"accessToken"`
+	TokenType    string    `json:"tokenType,omitempty"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+func (t *oauthToken) valid(now time.Time) bool {
+	return t.AccessToken != "" && now.Add(tokenExpirySkew).Before(t.ExpiresAt)
+}
+
+func (c OAuth2Config) validate() error {
+	switch c.GrantType {
+	case OAuth2ClientCredentials:
+	case OAuth2Password:
+		if c.Username == "" {
+			return fmt.Errorf("the password grant needs a username")
+		}
+	default:
+		return fmt.Errorf("unsupported grant type: %q", c.GrantType)
+	}
+	switch c.ClientAuth {
+	case "", OAuth2AuthBody, OAuth2AuthBasic:
+	default:
+		return fmt.Errorf("unsupported client authentication: %q", c.ClientAuth)
+	}
+	if c.TokenURL == "" {
+		return fmt.Errorf("token URL is required")
+	}
+	if c.ClientID == "" {
+		return fmt.Errorf("client id is required")
+	}
+	if c.Variable != "" {
+		return validateVariableName(c.Variable)
+	}
+	return nil
+}
+
+func (c OAuth2Config) variable() string {
+	if c.Variable == "" {
+		return defaultTokenVariable
+	}
+	return c.Variable
+}
+
+// references returns the variables the fields refer to.
+func (c OAuth2Config) references() map[string]bool {
+	fields := append([]string{c.TokenURL, c.ClientID, c.ClientSecret, c.Username, c.Password}, c.Scopes...)
+	return referencedVariables(strings.Join(fields, "\n"))
+}
+
+// expand replaces the {{name}} references of every field with variables.
+func (c OAuth2Config) expand(vars map[string]string) (OAuth2Config, error) {
+	var firstErr error
+	fill := func(s string) string {
+		return templateVarRe.ReplaceAllStringFunc(s, func(match string) string {
+			name := templateVarRe.FindStringSubmatch(match)[1]
+			value, ok := vars[name]
+			if !ok && firstErr == nil {
+				firstErr = fmt.Errorf("oauth2 configuration uses undefined variable %q", name)
+			}
+			return value
+		})
+	}
+	expanded := c
+	expanded.TokenURL = fill(c.TokenURL)
+	expanded.ClientID = fill(c.ClientID)
+	expanded.ClientSecret = fill(c.ClientSecret)
+	expanded.Username = fill(c.Username)
+	expanded.Password = fill(c.Password)
+	expanded.Scopes = make([]string, len(c.Scopes))
+	for i, scope := range c.Scopes {
+		expanded.Scopes[i] = fill(scope)
+	}
+	return expanded, firstErr
+}
+
+// cacheKey identifies the tokens of an expanded configuration. Changing any
+// field, the secret included, leaves the old token behind.
+func (c OAuth2Config) cacheKey() []byte {
+	data, _ := json.Marshal(c)
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// requestToken posts form to the token endpoint of config, adding the client
+// credentials.
+func requestToken(client *http.Client, config OAuth2Config, form url.Values) (*oauthToken, error) {
+	if config.ClientAuth != OAuth2AuthBasic {
+		form.Set("client_id", config.ClientID)
+		if config.ClientSecret != "" {
+			form.Set("client_secret", config.ClientSecret)
+		}
+	}
+	req, err := http.NewRequest(http.MethodPost, config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if config.ClientAuth == OAuth2AuthBasic {
+		req.SetBasicAuth(url.QueryEscape(config.ClientID), url.QueryEscape(config.ClientSecret))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	var parsed tokenResponse
+	parseErr := json.Unmarshal(body, &parsed)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if parseErr == nil && parsed.Error != "" {
+			return nil, fmt.Errorf("token request failed with %s: %s %s", resp.Status, parsed.Error, parsed.ErrorDescription)
+		}
+		return nil, fmt.Errorf("token request failed with %s", resp.Status)
+	}
+	if parseErr != nil {
+		return nil, fmt.Errorf("failed to parse token response: %w", parseErr)
+	}
+	if parsed.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access_token")
+	}
+
+	lifetime := defaultTokenLifetime
+	if parsed.ExpiresIn > 0 {
+		lifetime = time.Duration(parsed.ExpiresIn) * time.Second
+	}
+	return &oauthToken{
+		AccessToken:  parsed.AccessToken,
+		TokenType:    parsed.TokenType,
+		RefreshToken: parsed.RefreshToken,
+		ExpiresAt:    time.Now().Add(lifetime),
+	}, nil
+}
+
+// fetchToken gets a new token, with the refresh token of previous when there
+// is one, and else with the grant of config.
+func fetchToken(client *http.Client, config OAuth2Config, previous *oauthToken) (*oauthToken, error) {
+	if previous != nil && previous.RefreshToken != "" {
+		form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {previous.RefreshToken}}
+		if token, err := requestToken(client, config, form); err == nil {
+			if token.RefreshToken == "" {
+				token.RefreshToken = previous.RefreshToken
+			}
+			return token, nil
+		}
+		// The refresh token may have expired or been revoked: use the grant
+	}
+
+	form := url.Values{"grant_type": {config.GrantType}}
+	if config.GrantType == OAuth2Password {
+		form.Set("username", config.Username)
+		form.Set("password", config.Password)
+	}
+	if len(config.Scopes) > 0 {
+		form.Set("scope", strings.Join(config.Scopes, " "))
+	}
+	return requestToken(client, config, form)
+}
+
+// tokenCacheKey returns the key encrypting cached tokens, created on first use.
+func (a *App) tokenCacheKey() ([]byte, error) {
+	configDir, err := a.getConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(configDir, oauthTokenKeyFile)
+	key, err := os.ReadFile(path)
+	if err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read token key: %w", err)
+	}
+	// A missing or damaged key only loses the cached tokens
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, key, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save token key: %w", err)
+	}
+	return key, nil
+}
+
+// cachedToken returns the cached token for key. Entries that cannot be
+// decrypted, written with another key or before tokens were encrypted, are
+// dropped.
+func (a *App) cachedToken(key []byte) *oauthToken {
+	if a.cacheDB == nil {
+		return nil
+	}
+	secret, err := a.tokenCacheKey()
+	if err != nil {
+		fmt.Printf("Failed to load oauth2 token key: %v\n", err)
+		return nil
+	}
+	var token *oauthToken
+	a.cacheDB.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(oauthTokenBucket))
+		if bucket == nil {
+			return nil
+		}
+		data := bucket.Get(key)
+		if data == nil {
+			return nil
+		}
+		var t oauthToken
+		plaintext, err := openSecret(secret, hex.EncodeToString(key), string(data))
+		if err != nil || json.Unmarshal([]byte(plaintext), &t) != nil {
+			return bucket.Delete(key)
+		}
+		token = &t
+		return nil
+	})
+	return token
+}
+
+func (a *App) storeToken(key []byte, token *oauthToken) error {
+	if a.cacheDB == nil {
+		return nil
+	}
+	secret, err := a.tokenCacheKey()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	sealed, err := sealSecret(secret, hex.EncodeToString(key), string(data))
+	if err != nil {
+		return err
+	}
+	return a.cacheDB.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(oauthTokenBucket))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, []byte(sealed))
+	})
+}
+
+// oauthConfig returns the OAuth2 configuration of envName, or of the closest
+// environment it extends. Later env layers win.
+func (a *App) oauthConfig(start string, envName string) (*OAuth2Config, error) {
+	if envName == "" {
+		return nil, nil
+	}
+	sources, err := a.envSources(start)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := envChain(sources, envName)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for j := len(sources) - 1; j >= 0; j-- {
+			if config, ok := sources[j].Config.OAuth2[chain[i]]; ok {
+				return &config, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// oauthToken returns a valid token for config, from the cache unless it
+// expired or force is set.
+func (a *App) oauthToken(config OAuth2Config, vars map[string]string, force bool) (*oauthToken, bool, error) {
+	if err := config.validate(); err != nil {
+		return nil, false, fmt.Errorf("invalid oauth2 configuration: %w", err)
+	}
+	expanded, err := config.expand(vars)
+	if err != nil {
+		return nil, false, err
+	}
+	key := expanded.cacheKey()
+	cached := a.cachedToken(key)
+	if cached != nil && !force && cached.valid(time.Now()) {
+		return cached, true, nil
+	}
+
+	token, err := fetchToken(oauthHTTPClient, expanded, cached)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := a.storeToken(key, token); err != nil {
+		fmt.Printf("Failed to cache oauth2 token: %v\n", err)
+	}
+	return token, false, nil
+}
+
+// injectOAuth2Token sets the token variable of config, the OAuth2
+// configuration of the run if any. vars are the resolved variables of the run.
+// It returns the access and refresh tokens, which must not show in results.
+func (a *App) injectOAuth2Token(config *OAuth2Config, vars map[string]string) ([]string, error) {
+	if config == nil {
+		return nil, nil
+	}
+	token, _, err := a.oauthToken(*config, vars, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get oauth2 token: %w", err)
+	}
+	vars[config.variable()] = token.AccessToken
+	return []string{token.AccessToken, token.RefreshToken}, nil
+}
+
+// GetOAuth2Config returns the OAuth2 configuration of envName in the user env
+// config, if any.
+func (a *App) GetOAuth2Config(envName string) ReturnValue {
+	config, err := a.loadEnvConfig()
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	if oauth, ok := config.OAuth2[envName]; ok {
+		return ReturnValue{OAuth2: &oauth}
+	}
+	return ReturnValue{}
+}
+
+func (a *App) SetOAuth2Config(envName string, oauth OAuth2Config) ReturnValue {
+	return a.updateEnvConfig(func(config *EnvConfig) error {
+		if _, exists := config.Environments[envName]; !exists {
+			return fmt.Errorf("environment does not exist: %s", envName)
+		}
+		if err := oauth.validate(); err != nil {
+			return err
+		}
+		if config.OAuth2 == nil {
+			config.OAuth2 = map[string]OAuth2Config{}
+		}
+		config.OAuth2[envName] = oauth
+		return nil
+	})
+}
+
+func (a *App) DeleteOAuth2Config(envName string) ReturnValue {
+	return a.updateEnvConfig(func(config *EnvConfig) error {
+		if _, exists := config.OAuth2[envName]; !exists {
+			return fmt.Errorf("environment %s has no oauth2 configuration", envName)
+		}
+		delete(config.OAuth2, envName)
+		return nil
+	})
+}
+
+// FetchOAuth2Token requests a new token for envName, bypassing the cache, to
+// check the configuration. The token itself is not returned.
+func (a *App) FetchOAuth2Token(filePath string, envName string) ReturnValue {
+	filePath, err := a.sandboxStart(filePath)
+	if err != nil {
+		return errorResult(err)
+	}
+	config, err := a.oauthConfig(filePath, envName)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	if config == nil {
+		return ReturnValue{Error: fmt.Sprintf("environment %s has no oauth2 configuration", envName)}
+	}
+	vars, _, err := a.expandedVariables(filePath, envName, config.references())
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	token, cached, err := a.oauthToken(*config, vars, true)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	return ReturnValue{OAuth2Token: &OAuth2TokenInfo{
+		Variable:  config.variable(),
+		TokenType: token.TokenType,
+		ExpiresAt: token.ExpiresAt,
+		Cached:    cached,
+	}}
+}
+
+// ClearOAuth2Tokens forgets every cached token.
+func (a *App) ClearOAuth2Tokens() ReturnValue {
+	if a.cacheDB == nil {
+		return ReturnValue{}
+	}
+	err := a.cacheDB.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(oauthTokenBucket)) == nil {
+			return nil
+		}
+		return tx.DeleteBucket([]byte(oauthTokenBucket))
+	})
+	if err != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to clear oauth2 tokens: %v", err)}
+	}
+	return ReturnValue{}
+}
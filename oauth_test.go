@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// tokenServer is a fake OAuth2 token endpoint. It issues access-1, access-2...
+// and a refresh token with every grant but the refresh one.
+type tokenServer struct {
+	*httptest.Server
+	expiresIn     int64
+	rejectRefresh bool
+
+	mu       sync.Mutex
+	requests []tokenRequest
+}
+
+type tokenRequest struct {
+	form     url.Values
+	user     string
+	password string
+}
+
+func newTokenServer(t *testing.T) *tokenServer {
+	s := &tokenServer{expiresIn: 3600}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *tokenServer) handle(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, password, _ := r.BasicAuth()
+	s.mu.Lock()
+	s.requests = append(s.requests, tokenRequest{form: r.PostForm, user: user, password: password})
+	n := len(s.requests)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	grant := r.PostForm.Get("grant_type")
+	if grant == "refresh_token" && s.rejectRefresh {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	resp := map[string]any{
+		"access_token": fmt.Sprintf("access-%d", n),
+		"token_type":   "Bearer",
+		"expires_in":   s.expiresIn,
+	}
+	if grant != "refresh_token" {
+		resp["refresh_token"] = fmt.Sprintf("refresh-%d", n)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *tokenServer) grants() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var grants []string
+	for _, req := range s.requests {
+		grants = append(grants, req.form.Get("grant_type"))
+	}
+	return grants
+}
+
+func (s *tokenServer) last() tokenRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[len(s.requests)-1]
+}
+
+func TestOAuthTokenGrants(t *testing.T) {
+	vars := map[string]string{"client_secret": "s3cret", "user": "alice", "pass": "hunter2"}
+	tests := []struct {
+		name   string
+		config OAuth2Config
+		check  func(t *testing.T, req tokenRequest)
+	}{
+		{
+			name: "client credentials",
+			config: OAuth2Config{
+				GrantType:    OAuth2ClientCredentials,
+				ClientID:     "studio",
+				ClientSecret: "{{client_secret}}",
+				Scopes:       []string{"read", "write"},
+			},
+			check: func(t *testing.T, req tokenRequest) {
+				want := url.Values{
+					"grant_type":    {"client_credentials"},
+					"client_id":     {"studio"},
+					"client_secret": {"s3cret"},
+					"scope":         {"read write"},
+				}
+				if req.form.Encode() != want.Encode() {
+					t.Errorf("form = %s, want %s", req.form.Encode(), want.Encode())
+				}
+			},
+		},
+		{
+			name: "password with basic client auth",
+			config: OAuth2Config{
+				GrantType:    OAuth2Password,
+				ClientID:     "studio",
+				ClientSecret: "{{client_secret}}",
+				ClientAuth:   OAuth2AuthBasic,
+				Username:     "{{user}}",
+				Password:     "{{pass}}",
+			},
+			check: func(t *testing.T, req tokenRequest) {
+				want := url.Values{
+					"grant_type": {"password"},
+					"username":   {"alice"},
+					"password":   {"hunter2"},
+				}
+				if req.form.Encode() != want.Encode() {
+					t.Errorf("form = %s, want %s", req.form.Encode(), want.Encode())
+				}
+				if req.user != "studio" || req.password != "s3cret" {
+					t.Errorf("basic auth = %s:%s, want studio:s3cret", req.user, req.password)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(t)
+			server := newTokenServer(t)
+			tt.config.TokenURL = server.URL
+
+			token, cached, err := a.oauthToken(tt.config, vars, false)
+			if err != nil {
+				t.Fatalf("oauthToken: %v", err)
+			}
+			if cached || token.AccessToken != "access-1" {
+				t.Fatalf("got %q cached=%v, want access-1 fetched", token.AccessToken, cached)
+			}
+			tt.check(t, server.last())
+
+			// A valid token comes from the cache
+			token, cached, err = a.oauthToken(tt.config, vars, false)
+			if err != nil {
+				t.Fatalf("oauthToken: %v", err)
+			}
+			if !cached || token.AccessToken != "access-1" {
+				t.Errorf("got %q cached=%v, want access-1 cached", token.AccessToken, cached)
+			}
+			if n := len(server.grants()); n != 1 {
+				t.Errorf("%d token requests, want 1", n)
+			}
+		})
+	}
+}
+
+func TestOAuthTokenRenewal(t *testing.T) {
+	tests := []struct {
+		name          string
+		expiresIn     int64
+		rejectRefresh bool
+		force         bool
+		wantGrants    []string
+		wantRefresh   string
+	}{
+		{
+			name:        "expired token is refreshed",
+			expiresIn:   10, // within the expiry skew
+			wantGrants:  []string{"client_credentials", "refresh_token"},
+			wantRefresh: "refresh-1",
+		},
+		{
+			name:          "rejected refresh falls back to the grant",
+			expiresIn:     10,
+			rejectRefresh: true,
+			wantGrants:    []string{"client_credentials", "refresh_token", "client_credentials"},
+			wantRefresh:   "refresh-3",
+		},
+		{
+			name:        "force bypasses a valid token",
+			expiresIn:   3600,
+			force:       true,
+			wantGrants:  []string{"client_credentials", "refresh_token"},
+			wantRefresh: "refresh-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(t)
+			server := newTokenServer(t)
+			server.expiresIn = tt.expiresIn
+			server.rejectRefresh = tt.rejectRefresh
+			config := OAuth2Config{GrantType: OAuth2ClientCredentials, TokenURL: server.URL, ClientID: "studio"}
+
+			first, _, err := a.oauthToken(config, nil, false)
+			if err != nil {
+				t.Fatalf("oauthToken: %v", err)
+			}
+			second, cached, err := a.oauthToken(config, nil, tt.force)
+			if err != nil {
+				t.Fatalf("oauthToken: %v", err)
+			}
+			if cached || second.AccessToken == first.AccessToken {
+				t.Errorf("got %q cached=%v, want a new token", second.AccessToken, cached)
+			}
+			if second.RefreshToken != tt.wantRefresh {
+				t.Errorf("refresh token = %q, want %q", second.RefreshToken, tt.wantRefresh)
+			}
+			if got := strings.Join(server.grants(), ","); got != strings.Join(tt.wantGrants, ",") {
+				t.Errorf("grants = %s, want %s", got, strings.Join(tt.wantGrants, ","))
+			}
+		})
+	}
+}
+
+func TestOAuthTokenCache(t *testing.T) {
+	a := newTestApp(t)
+	server := newTokenServer(t)
+	config := OAuth2Config{GrantType: OAuth2ClientCredentials, TokenURL: server.URL, ClientID: "studio", ClientSecret: "{{secret}}"}
+
+	if _, _, err := a.oauthToken(config, map[string]string{"secret": "one"}, false); err != nil {
+		t.Fatalf("oauthToken: %v", err)
+	}
+
+	// Tokens are not stored in clear text
+	a.cacheDB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(oauthTokenBucket)).ForEach(func(k, v []byte) error {
+			if strings.Contains(string(v), "access-1") || strings.Contains(string(v), "refresh-1") {
+				t.Errorf("cached token in clear text: %s", v)
+			}
+			return nil
+		})
+	})
+	configDir, _ := a.getConfigDir()
+	info, err := os.Stat(filepath.Join(configDir, oauthTokenKeyFile))
+	if err != nil {
+		t.Fatalf("token key: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("token key mode = %o, want 600", perm)
+	}
+
+	// Another secret is another configuration
+	token, cached, err := a.oauthToken(config, map[string]string{"secret": "two"}, false)
+	if err != nil {
+		t.Fatalf("oauthToken: %v", err)
+	}
+	if cached || token.AccessToken != "access-2" {
+		t.Errorf("got %q cached=%v, want access-2 fetched", token.AccessToken, cached)
+	}
+
+	// A new key makes the cached tokens unreadable, and they are dropped
+	os.Remove(filepath.Join(configDir, oauthTokenKeyFile))
+	token, cached, err = a.oauthToken(config, map[string]string{"secret": "one"}, false)
+	if err != nil {
+		t.Fatalf("oauthToken: %v", err)
+	}
+	if cached || token.AccessToken != "access-3" {
+		t.Errorf("got %q cached=%v, want access-3 fetched", token.AccessToken, cached)
+	}
+	if got := strings.Join(server.grants(), ","); got != "client_credentials,client_credentials,client_credentials" {
+		t.Errorf("grants = %s", got)
+	}
+
+	// ClearOAuth2Tokens forgets them
+	if result := a.ClearOAuth2Tokens(); result.Error != "" {
+		t.Fatalf("ClearOAuth2Tokens: %s", result.Error)
+	}
+	if _, cached, _ := a.oauthToken(config, map[string]string{"secret": "one"}, false); cached {
+		t.Error("token still cached after ClearOAuth2Tokens")
+	}
+}
+
+func TestOAuthTokenErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"invalid_client","error_description":"bad secret"}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		config  OAuth2Config
+		vars    map[string]string
+		wantErr string
+	}{
+		{
+			name:    "endpoint error",
+			config:  OAuth2Config{GrantType: OAuth2ClientCredentials, TokenURL: server.URL, ClientID: "studio"},
+			wantErr: "invalid_client bad secret",
+		},
+		{
+			name:    "undefined variable",
+			config:  OAuth2Config{GrantType: OAuth2ClientCredentials, TokenURL: server.URL, ClientID: "{{missing}}"},
+			wantErr: `undefined variable "missing"`,
+		},
+		{
+			name:    "password grant without username",
+			config:  OAuth2Config{GrantType: OAuth2Password, TokenURL: server.URL, ClientID: "studio"},
+			wantErr: "needs a username",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(t)
+			_, _, err := a.oauthToken(tt.config, tt.vars, false)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
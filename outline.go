@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	EntryStatusPassed  = "passed"
+	EntryStatusFailed  = "failed"
+	EntryStatusSkipped = "skipped"
+)
+
+// OutlineEntry summarizes one entry of a .hurl file for the outline panel.
+type OutlineEntry struct {
+	Index     int    `json:"index"`
+	Method    string `json:"method"`
+	URL       string `json:"url"`
+	StartLine int    `json:"startLine"`
+	EndLine   int    `json:"endLine"`
+	Captures  int    `json:"captures"`
+	Asserts   int    `json:"asserts"`
+	// Status of the entry in the last cached run, empty if the file never ran.
+	Status string `json:"status,omitempty"`
+}
+
+// loadCachedReport reads the report of the last run of filePath, if any.
+func loadCachedReport(filePath string) (HurlReport, bool) {
+	data, err := os.ReadFile(reportPathFor(filePath))
+	if err != nil {
+		return nil, false
+	}
+	var report HurlReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		fmt.Printf("Failed to parse cached JSON report: %v\n", err)
+		return nil, false
+	}
+	return report, true
+}
+
+// entryStatuses maps entry indexes (1-based, as in the hurl report) to a status.
+// Hurl stops at the first failing entry, so entries missing from a report are skipped.
+func entryStatuses(report HurlReport) map[int]string {
+	statuses := map[int]string{}
+	for _, session := range report {
+		for i, entry := range session.Entries {
+			status := EntryStatusPassed
+			for _, assert := range entry.Asserts {
+				if m, ok := assert.(map[string]interface{}); ok {
+					if success, ok := m["success"].(bool); ok && !success {
+						status = EntryStatusFailed
+					}
+				}
+			}
+			if !session.Success && i == len(session.Entries)-1 {
+				status = EntryStatusFailed
+			}
+			statuses[entry.Index] = status
+		}
+	}
+	return statuses
+}
+
+// GetFileOutline returns every entry of a .hurl file with its position and
+// the status of the entry in the last run.
+func (a *App) GetFileOutline(filePath string) ReturnValue {
+	filePath, err := a.sandboxPath(filePath, false)
+	if err != nil {
+		return errorResult(err)
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to read file: %v", err)}
+	}
+
+	parsed := parseHurlFile(string(content))
+	report, hasReport := loadCachedReport(filePath)
+	statuses := entryStatuses(report)
+
+	outline := make([]OutlineEntry, 0, len(parsed.Entries))
+	for _, e := range parsed.Entries {
+		item := OutlineEntry{
+			Index:     e.Index,
+			Method:    e.Method,
+			URL:       e.URL,
+			StartLine: e.StartLine,
+			EndLine:   e.EndLine,
+			Captures:  len(e.Captures),
+			Asserts:   e.Asserts,
+		}
+		if hasReport {
+			if status, ok := statuses[e.Index]; ok {
+				item.Status = status
+			} else {
+				item.Status = EntryStatusSkipped
+			}
+		}
+		outline = append(outline, item)
+	}
+
+	return ReturnValue{Outline: outline}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Folder holding project level settings, next to the .hurl files of a repo.
+const projectConfigDirName = ".hurlstudio"
+
+// findProjectRoot walks up from start looking for a folder containing
+// .hurlstudio and returns it, or "" when there is none.
+func findProjectRoot(start string) string {
+	dir := filepath.Clean(start)
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	for {
+		if info, err := os.Stat(filepath.Join(dir, projectConfigDirName)); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
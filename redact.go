@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Secret values shorter than this are not redacted, replacing them would
+// mangle unrelated text.
+const minRedactedSecretLength = 4
+
+// RedactionConfig controls what is masked in reports before they are returned
+// to the UI or stored under TEMP_DIR_PATH.
+type RedactionConfig struct {
+	// Header names, compared case-insensitively. Cookie and Set-Cookie also
+	// mask the parsed cookies of requests and responses.
+	Headers []string `json:"headers"`
+	// Query string parameter names.
+	QueryParams []string `json:"queryParams"`
+	// RedactSecrets masks the values of secret variables everywhere.
+	RedactSecrets bool   `json:"redactSecrets"`
+	Replacement   string `json:"replacement"`
+}
+
+func defaultRedactionConfig() RedactionConfig {
+	return RedactionConfig{
+		Headers:       []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"},
+		QueryParams:   []string{"access_token", "api_key", "apikey", "token"},
+		RedactSecrets: true,
+		Replacement:   "********",
+	}
+}
+
+// Raw reports of the runs of this session, kept in memory only so the UI can
+// reveal them on demand.
+type revealStore struct {
+	mu      sync.Mutex
+	reports map[string]HurlReport
+}
+
+func (r *revealStore) set(filePath string, report HurlReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.reports == nil {
+		r.reports = map[string]HurlReport{}
+	}
+	r.reports[filePath] = report
+}
+
+func (r *revealStore) get(filePath string) (HurlReport, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report, ok := r.reports[filePath]
+	return report, ok
+}
+
+type redactor struct {
+	config  RedactionConfig
+	headers map[string]bool
+	params  map[string]bool
+	secrets []string
+	curlRe  *regexp.Regexp
+}
+
+func newRedactor(config RedactionConfig, secrets []string) *redactor {
+	r := &redactor{
+		config:  config,
+		headers: map[string]bool{},
+		params:  map[string]bool{},
+	}
+	if r.config.Replacement == "" {
+		r.config.Replacement = defaultRedactionConfig().Replacement
+	}
+
+	var names []string
+	for _, h := range config.Headers {
+		r.headers[strings.ToLower(h)] = true
+		names = append(names, regexp.QuoteMeta(h))
+	}
+	for _, p := range config.QueryParams {
+		r.params[p] = true
+	}
+	if len(names) > 0 {
+		// --header 'Name: value' and -H "Name: value" in curl commands
+		r.curlRe = regexp.MustCompile(`(?i)((?:--header|-H)\s+['"](?:` + strings.Join(names, "|") + `)\s*:\s*)([^'"]*)`)
+	}
+
+	r.addSecrets(secrets...)
+	return r
+}
+
+// addSecrets masks more values, like the tokens and hook variables of a run.
+func (r *redactor) addSecrets(secrets ...string) {
+	if !r.config.RedactSecrets {
+		return
+	}
+	for _, s := range secrets {
+		if len(s) >= minRedactedSecretLength {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	// Longest first so a secret containing another one is fully masked
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+}
+
+func (r *redactor) text(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, r.config.Replacement)
+	}
+	return s
+}
+
+func (r *redactor) curl(cmd string) string {
+	if r.curlRe != nil {
+		cmd = r.curlRe.ReplaceAllString(cmd, "${1}"+r.config.Replacement)
+	}
+	if r.headers["cookie"] {
+		cmd = regexp.MustCompile(`((?:--cookie|-b)\s+['"])([^'"]*)`).ReplaceAllString(cmd, "${1}"+r.config.Replacement)
+	}
+	return r.text(r.url(cmd))
+}
+
+func (r *redactor) url(s string) string {
+	for p := range r.params {
+		re := regexp.MustCompile(`([?&]` + regexp.QuoteMeta(p) + `=)[^&'"\s#]*`)
+		s = re.ReplaceAllString(s, "${1}"+r.config.Replacement)
+	}
+	return s
+}
+
+func (r *redactor) headerList(headers []HurlHeader) {
+	for i := range headers {
+		if r.headers[strings.ToLower(headers[i].Name)] {
+			headers[i].Value = r.config.Replacement
+		} else {
+			headers[i].Value = r.text(headers[i].Value)
+		}
+	}
+}
+
+func (r *redactor) cookies(cookies []HurlCookie) {
+	mask := r.headers["cookie"] || r.headers["set-cookie"]
+	for i := range cookies {
+		if mask {
+			cookies[i].Value = r.config.Replacement
+		} else {
+			cookies[i].Value = r.text(cookies[i].Value)
+		}
+	}
+}
+
+// any redacts the secrets in the string values of decoded JSON, as found in
+// asserts and captures.
+func (r *redactor) any(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		return r.text(t)
+	case map[string]interface{}:
+		for k, item := range t {
+			t[k] = r.any(item)
+		}
+	case []interface{}:
+		for i, item := range t {
+			t[i] = r.any(item)
+		}
+	}
+	return v
+}
+
+func (r *redactor) report(report HurlReport) {
+	for i := range report {
+		session := &report[i]
+		r.cookies(session.Cookies)
+		for j := range session.Entries {
+			entry := &session.Entries[j]
+			entry.CurlCmd = r.curl(entry.CurlCmd)
+			for k := range entry.Asserts {
+				entry.Asserts[k] = r.any(entry.Asserts[k])
+			}
+			for k := range entry.Captures {
+				entry.Captures[k] = r.any(entry.Captures[k])
+			}
+			for k := range entry.Calls {
+				call := &entry.Calls[k]
+				call.Request.URL = r.text(r.url(call.Request.URL))
+				r.headerList(call.Request.Headers)
+				r.cookies(call.Request.Cookies)
+				for q := range call.Request.QueryString {
+					param := &call.Request.QueryString[q]
+					if r.params[param.Name] {
+						param.Value = r.config.Replacement
+					} else {
+						param.Value = r.text(param.Value)
+					}
+				}
+				r.headerList(call.Response.Headers)
+				r.cookies(call.Response.Cookies)
+				call.Response.Body = r.text(call.Response.Body)
+			}
+		}
+	}
+}
+
+// storeFiles redacts the secrets in the response bodies hurl stored under dir.
+func (r *redactor) storeFiles(dir string) {
+	if len(r.secrets) == 0 {
+		return
+	}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		if redacted := r.text(string(data)); redacted != string(data) {
+			if err := writeFileAtomic(path, []byte(redacted), 0644); err != nil {
+				fmt.Printf("Failed to redact %s: %v\n", path, err)
+			}
+		}
+		return nil
+	})
+}
+
+func copyReport(report HurlReport) HurlReport {
+	var copied HurlReport
+	if data, err := json.Marshal(report); err == nil {
+		json.Unmarshal(data, &copied)
+	}
+	return copied
+}
+
+func (a *App) getRedactionFilePath() (string, error) {
+	configDir, err := a.getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "redaction.json"), nil
+}
+
+func (a *App) loadRedactionConfig() (RedactionConfig, error) {
+	path, err := a.getRedactionFilePath()
+	if err != nil {
+		return RedactionConfig{}, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return defaultRedactionConfig(), nil
+	}
+	if err != nil {
+		return RedactionConfig{}, fmt.Errorf("failed to read redaction config: %w", err)
+	}
+	config := defaultRedactionConfig()
+	if err := json.Unmarshal(data, &config); err != nil {
+		return RedactionConfig{}, fmt.Errorf("failed to parse redaction config: %w", err)
+	}
+	return config, nil
+}
+
+func (a *App) newRedactor(secrets []string) *redactor {
+	config, err := a.loadRedactionConfig()
+	if err != nil {
+		fmt.Printf("Failed to load redaction config, using defaults: %v\n", err)
+		config = defaultRedactionConfig()
+	}
+	return newRedactor(config, secrets)
+}
+
+func (a *App) GetRedactionConfig() ReturnValue {
+	config, err := a.loadRedactionConfig()
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	return ReturnValue{Redaction: &config}
+}
+
+func (a *App) SetRedactionConfig(config RedactionConfig) ReturnValue {
+	path, err := a.getRedactionFilePath()
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to marshal redaction config: %v", err)}
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to write redaction config: %v", err)}
+	}
+	return ReturnValue{Redaction: &config}
+}
+
+// RevealHurlResult returns the unredacted report of the last run of filePath.
+// Raw reports are only kept in memory, so this works for runs of the current session.
+func (a *App) RevealHurlResult(filePath string) ReturnValue {
+	report, ok := a.revealed.get(filePath)
+	if !ok {
+		return ReturnValue{Error: "no unredacted result for this file in the current session"}
+	}
+	return ReturnValue{HurlReport: report}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRedactorText(t *testing.T) {
+	config := defaultRedactionConfig()
+	tests := []struct {
+		name    string
+		config  RedactionConfig
+		secrets []string
+		in      string
+		want    string
+	}{
+		{
+			name:    "secrets masked",
+			config:  config,
+			secrets: []string{"s3cret-token"},
+			in:      "token s3cret-token twice s3cret-token",
+			want:    "token ******** twice ********",
+		},
+		{
+			name:    "longest secret first",
+			config:  config,
+			secrets: []string{"abcd", "abcdef-ghij"},
+			in:      "abcdef-ghij abcd",
+			want:    "******** ********",
+		},
+		{
+			name:    "short secrets kept",
+			config:  config,
+			secrets: []string{"abc"},
+			in:      "abc abcd",
+			want:    "abc abcd",
+		},
+		{
+			name:    "secrets not redacted when disabled",
+			config:  RedactionConfig{Replacement: "xx"},
+			secrets: []string{"s3cret-token"},
+			in:      "s3cret-token",
+			want:    "s3cret-token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newRedactor(tt.config, tt.secrets).text(tt.in); got != tt.want {
+				t.Errorf("text(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorCurl(t *testing.T) {
+	r := newRedactor(defaultRedactionConfig(), []string{"p4ssword"})
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{
+			in:   `curl --header 'Authorization: Bearer abc' 'http://x/a'`,
+			want: `curl --header 'Authorization: ********' 'http://x/a'`,
+		},
+		{
+			in:   `curl -H "x-api-key: k" -H 'Accept: json' 'http://x/?token=abc&page=2#top'`,
+			want: `curl -H "x-api-key: ********" -H 'Accept: json' 'http://x/?token=********&page=2#top'`,
+		},
+		{
+			in:   `curl --cookie 'session=abc' --data 'password=p4ssword' http://x`,
+			want: `curl --cookie '********' --data 'password=********' http://x`,
+		},
+	}
+	for _, tt := range tests {
+		if got := r.curl(tt.in); got != tt.want {
+			t.Errorf("curl(%q) =\n%q\nwant\n%q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactorReport(t *testing.T) {
+	r := newRedactor(defaultRedactionConfig(), []string{"s3cret-token"})
+	report := HurlReport{{
+		Cookies: []HurlCookie{{Name: "session", Value: "abc"}},
+		Entries: []HurlEntry{{
+			Asserts:  []interface{}{map[string]interface{}{"actual": "s3cret-token", "success": true}},
+			Captures: []interface{}{map[string]interface{}{"name": "token", "value": []interface{}{"s3cret-token"}}},
+			Calls: []HurlCall{{
+				Request: HurlRequest{
+					URL:         "http://x/?api_key=k&q=s3cret-token",
+					Headers:     []HurlHeader{{Name: "authorization", Value: "Bearer s"}, {Name: "X-Echo", Value: "s3cret-token"}},
+					QueryString: []HurlQueryParam{{Name: "api_key", Value: "k"}, {Name: "q", Value: "s3cret-token"}},
+				},
+				Response: HurlResponse{Body: `{"token": "s3cret-token"}`},
+			}},
+		}},
+	}}
+	raw := copyReport(report)
+	r.report(report)
+
+	call := report[0].Entries[0].Calls[0]
+	checks := map[string]string{
+		"cookie":        report[0].Cookies[0].Value,
+		"assert":        report[0].Entries[0].Asserts[0].(map[string]interface{})["actual"].(string),
+		"capture":       report[0].Entries[0].Captures[0].(map[string]interface{})["value"].([]interface{})[0].(string),
+		"url":           call.Request.URL,
+		"header":        call.Request.Headers[0].Value,
+		"other header":  call.Request.Headers[1].Value,
+		"query param":   call.Request.QueryString[0].Value,
+		"other param":   call.Request.QueryString[1].Value,
+		"response body": call.Response.Body,
+		"raw is a copy": raw[0].Entries[0].Calls[0].Request.QueryString[1].Value,
+	}
+	want := map[string]string{
+		"cookie":        "********",
+		"assert":        "********",
+		"capture":       "********",
+		"url":           "http://x/?api_key=********&q=********",
+		"header":        "********",
+		"other header":  "********",
+		"query param":   "********",
+		"other param":   "********",
+		"response body": `{"token": "********"}`,
+		"raw is a copy": "s3cret-token",
+	}
+	for name, got := range checks {
+		if got != want[name] {
+			t.Errorf("%s = %q, want %q", name, got, want[name])
+		}
+	}
+	if success := report[0].Entries[0].Asserts[0].(map[string]interface{})["success"]; success != true {
+		t.Errorf("assert success = %v, want true", success)
+	}
+}
+
+func TestRedactorStoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	body := filepath.Join(dir, "call", "body.json")
+	other := filepath.Join(dir, "other.txt")
+	os.MkdirAll(filepath.Dir(body), 0755)
+	os.WriteFile(body, []byte(`{"token": "s3cret-token"}`), 0644)
+	os.WriteFile(other, []byte("nothing to hide"), 0644)
+
+	newRedactor(defaultRedactionConfig(), []string{"s3cret-token"}).storeFiles(dir)
+	for path, want := range map[string]string{body: `{"token": "********"}`, other: "nothing to hide"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), data, want)
+		}
+	}
+	if data, _ := os.ReadFile(body); strings.Contains(string(data), "s3cret") {
+		t.Errorf("stored body still holds the secret")
+	}
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const globalScope = "global"
+
+var validVariableNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_\-]*$`)
+
+type RenameEdit struct {
+	Line   int    `json:"line"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+type RenameFileChange struct {
+	Path  string       `json:"path"`
+	Edits []RenameEdit `json:"edits"`
+}
+
+// RenameEnvChange is an env file of the workspace where the variable is
+// renamed, with the scopes that changed.
+type RenameEnvChange struct {
+	Layer  string   `json:"layer"`
+	Path   string   `json:"path"`
+	Scopes []string `json:"scopes"`
+}
+
+// RenamePreview lists what RenameVariable changes. EnvScopes holds "global"
+// and the names of the environments of the user env.json using the variable,
+// SecretScopes the vault scopes holding it as a secret.
+type RenamePreview struct {
+	OldName      string             `json:"oldName"`
+	NewName      string             `json:"newName"`
+	Files        []RenameFileChange `json:"files"`
+	EnvScopes    []string           `json:"envScopes"`
+	EnvFiles     []RenameEnvChange  `json:"envFiles"`
+	SecretScopes []string           `json:"secretScopes"`
+}
+
+// renameInContent renames a variable in the content of a .hurl file: template
+// usages, capture definitions, [Options] variables and variable queries.
+func renameInContent(content string, oldName string, newName string) (string, []RenameEdit) {
+	q := regexp.QuoteMeta(oldName)
+	usageRe := regexp.MustCompile(`(\{\{\s*)` + q + `(\s*\}\})`)
+	captureRe := regexp.MustCompile(`^(\s*)` + q + `(\s*:)`)
+	optionRe := regexp.MustCompile(`^(\s*variable\s*:\s*)` + q + `(\s*=)`)
+	queryRe := regexp.MustCompile(`(\bvariable\s+")` + q + `(")`)
+
+	parsed := parseHurlFile(content)
+	var edits []RenameEdit
+	lines := make([]string, len(parsed.Lines))
+	for i, line := range parsed.Lines {
+		renamed := line
+		if !parsed.Info[i].Comment {
+			renamed = usageRe.ReplaceAllString(renamed, "${1}"+newName+"${2}")
+			switch parsed.Info[i].Section {
+			case "Captures":
+				renamed = captureRe.ReplaceAllString(renamed, "${1}"+newName+"${2}")
+				renamed = queryRe.ReplaceAllString(renamed, "${1}"+newName+"${2}")
+			case "Asserts":
+				renamed = queryRe.ReplaceAllString(renamed, "${1}"+newName+"${2}")
+			case "Options":
+				renamed = optionRe.ReplaceAllString(renamed, "${1}"+newName+"${2}")
+			}
+		}
+		if renamed != line {
+			edits = append(edits, RenameEdit{Line: i + 1, Before: line, After: renamed})
+		}
+		lines[i] = renamed
+	}
+
+	newline := "\n"
+	if strings.Contains(content, "\r\n") {
+		newline = "\r\n"
+	}
+	return strings.Join(lines, newline), edits
+}
+
+// renameInOAuth2Config renames the {{name}} references of every field and the
+// variable receiving the token, reporting whether anything changed.
+func renameInOAuth2Config(config *OAuth2Config, oldName string, newName string) bool {
+	usageRe := regexp.MustCompile(`(\{\{\s*)` + regexp.QuoteMeta(oldName) + `(\s*\}\})`)
+	changed := false
+	rename := func(s *string) {
+		if renamed := usageRe.ReplaceAllString(*s, "${1}"+newName+"${2}"); renamed != *s {
+			*s = renamed
+			changed = true
+		}
+	}
+	rename(&config.TokenURL)
+	rename(&config.ClientID)
+	rename(&config.ClientSecret)
+	rename(&config.Username)
+	rename(&config.Password)
+	for i := range config.Scopes {
+		rename(&config.Scopes[i])
+	}
+	if config.Variable == oldName {
+		config.Variable = newName
+		changed = true
+	}
+	return changed
+}
+
+// renameInEnvConfig renames the key in the global scope and every environment,
+// and the references of the OAuth2 configurations, returning the scopes that
+// changed.
+func renameInEnvConfig(config *EnvConfig, oldName string, newName string) ([]string, error) {
+	var scopes []string
+	changed := map[string]bool{}
+	rename := func(scope string, vars map[string]EnvValue) error {
+		value, ok := vars[oldName]
+		if !ok {
+			return nil
+		}
+		if _, exists := vars[newName]; exists {
+			return fmt.Errorf("variable %q already exists in %s", newName, scope)
+		}
+		delete(vars, oldName)
+		vars[newName] = value
+		scopes = append(scopes, scope)
+		changed[scope] = true
+		return nil
+	}
+
+	if err := rename(globalScope, config.Global); err != nil {
+		return nil, err
+	}
+	envNames := make([]string, 0, len(config.Environments))
+	for name := range config.Environments {
+		envNames = append(envNames, name)
+	}
+	sort.Strings(envNames)
+	for _, name := range envNames {
+		if err := rename(name, config.Environments[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	oauthNames := make([]string, 0, len(config.OAuth2))
+	for name := range config.OAuth2 {
+		oauthNames = append(oauthNames, name)
+	}
+	sort.Strings(oauthNames)
+	for _, name := range oauthNames {
+		oauth := config.OAuth2[name]
+		if !renameInOAuth2Config(&oauth, oldName, newName) {
+			continue
+		}
+		config.OAuth2[name] = oauth
+		if !changed[name] {
+			scopes = append(scopes, name)
+			changed[name] = true
+		}
+	}
+	return scopes, nil
+}
+
+// renameInVariablesFile renames the key of a file in the hurl
+// --variables-file format, keeping every other line as is.
+func renameInVariablesFile(path string, content string, oldName string, newName string) (string, []RenameEdit, error) {
+	lines := strings.Split(content, "\n")
+	var edits []RenameEdit
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		name, _, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		switch strings.TrimSpace(name) {
+		case newName:
+			return "", nil, fmt.Errorf("variable %q already exists in %s", newName, path)
+		case oldName:
+			renamed := strings.Replace(line, oldName, newName, 1)
+			edits = append(edits, RenameEdit{Line: i + 1, Before: line, After: renamed})
+			lines[i] = renamed
+		}
+	}
+	return strings.Join(lines, "\n"), edits, nil
+}
+
+// envFilesUnder returns the env files under root: those of every .hurlstudio
+// folder and the .hurlvars files.
+func envFilesUnder(root string) ([]EnvLayerInfo, error) {
+	var files []EnvLayerInfo
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable folders should not abort the whole walk
+			if d != nil && d.IsDir() && path != root {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == projectConfigDirName {
+				configDir := path
+				envFiles, _ := filepath.Glob(filepath.Join(configDir, "*.env"))
+				sort.Strings(envFiles)
+				for _, p := range append([]string{filepath.Join(configDir, "env.json")}, envFiles...) {
+					files = append(files, EnvLayerInfo{Layer: EnvLayerProject, Path: p})
+				}
+				files = append(files, EnvLayerInfo{Layer: EnvLayerLocal, Path: filepath.Join(configDir, "env.local.json")})
+				return fs.SkipDir
+			}
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		switch name := d.Name(); {
+		case name == hurlVarsFileName:
+			files = append(files, EnvLayerInfo{Layer: EnvLayerFolder, Path: path})
+		case strings.HasSuffix(name, hurlVarsFileName):
+			files = append(files, EnvLayerInfo{Layer: EnvLayerFile, Path: path})
+		}
+		return nil
+	})
+	return files, err
+}
+
+// renameInEnvFile renames the variable in an env file of the workspace,
+// returning its new content and the scopes that changed. A missing file
+// changes nothing.
+func renameInEnvFile(file EnvLayerInfo, oldName string, newName string) ([]byte, []string, error) {
+	content, err := os.ReadFile(file.Path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", file.Path, err)
+	}
+	if strings.HasSuffix(file.Path, ".json") {
+		config, err := parseEnvConfig(file.Path, content)
+		if err != nil {
+			return nil, nil, err
+		}
+		scopes, err := renameInEnvConfig(config, oldName, newName)
+		if err != nil || len(scopes) == 0 {
+			return nil, nil, err
+		}
+		data, err := marshalEnvConfig(config)
+		return data, scopes, err
+	}
+
+	renamed, edits, err := renameInVariablesFile(file.Path, string(content), oldName, newName)
+	if err != nil || len(edits) == 0 {
+		return nil, nil, err
+	}
+	// .env files hold one environment, .hurlvars files apply to all of them
+	scope := globalScope
+	if strings.HasSuffix(file.Path, ".env") {
+		scope = strings.TrimSuffix(filepath.Base(file.Path), ".env")
+	}
+	return []byte(renamed), []string{scope}, nil
+}
+
+// renameSecret re-encrypts the secret under the new name in every vault
+// scope holding it, the name being bound to the encrypted value. It returns
+// the new vault and the scopes that changed, a nil vault when none did.
+func (a *App) renameSecret(oldName string, newName string) (*vaultFile, []string, error) {
+	vf, err := a.loadVaultFile()
+	if err != nil || vf == nil {
+		return nil, nil, err
+	}
+	scopes := make([]string, 0, len(vf.Secrets))
+	for scope, secrets := range vf.Secrets {
+		if _, ok := secrets[oldName]; ok {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, nil, nil
+	}
+	sort.Strings(scopes)
+	key := a.vaultKey()
+	if key == nil {
+		return nil, nil, fmt.Errorf("%s is a secret: %w", oldName, errVaultLocked)
+	}
+	for _, scope := range scopes {
+		secrets := vf.Secrets[scope]
+		if _, exists := secrets[newName]; exists {
+			return nil, nil, fmt.Errorf("secret %q already exists in %s", newName, scope)
+		}
+		value, err := openSecret(key, oldName, secrets[oldName])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt secret %s: %w", oldName, err)
+		}
+		sealed, err := sealSecret(key, newName, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encrypt secret %s: %w", newName, err)
+		}
+		delete(secrets, oldName)
+		secrets[newName] = sealed
+	}
+	return vf, scopes, nil
+}
+
+// planRename computes the rename without touching the disk. It returns the
+// preview and the new content of every file to write.
+func (a *App) planRename(oldName string, newName string) (*RenamePreview, map[string][]byte, error) {
+	if !validVariableNameRe.MatchString(oldName) {
+		return nil, nil, fmt.Errorf("invalid variable name: %q", oldName)
+	}
+	if !validVariableNameRe.MatchString(newName) {
+		return nil, nil, fmt.Errorf("invalid variable name: %q", newName)
+	}
+	if oldName == newName {
+		return nil, nil, fmt.Errorf("new name is the same as the old name")
+	}
+
+	preview := &RenamePreview{OldName: oldName, NewName: newName, Files: []RenameFileChange{}}
+	writes := map[string][]byte{}
+
+	root := a.indexRoot()
+	if root == "" {
+		return nil, nil, fmt.Errorf("no workspace to rename in, open a folder as a workspace")
+	}
+	files, err := hurlFilesUnder(root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan workspace: %w", err)
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		renamed, edits := renameInContent(string(content), oldName, newName)
+		if len(edits) == 0 {
+			continue
+		}
+		preview.Files = append(preview.Files, RenameFileChange{Path: file, Edits: edits})
+		writes[file] = []byte(renamed)
+	}
+
+	envFiles, err := envFilesUnder(root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan workspace: %w", err)
+	}
+	preview.EnvFiles = []RenameEnvChange{}
+	for _, file := range envFiles {
+		data, scopes, err := renameInEnvFile(file, oldName, newName)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(scopes) == 0 {
+			continue
+		}
+		preview.EnvFiles = append(preview.EnvFiles, RenameEnvChange{Layer: file.Layer, Path: file.Path, Scopes: scopes})
+		writes[file.Path] = data
+	}
+
+	vf, secretScopes, err := a.renameSecret(oldName, newName)
+	if err != nil {
+		return nil, nil, err
+	}
+	preview.SecretScopes = secretScopes
+	if vf != nil {
+		vaultPath, err := a.getVaultFilePath()
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := json.MarshalIndent(vf, "", "  ")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal vault: %w", err)
+		}
+		writes[vaultPath] = data
+	}
+
+	config, err := a.loadEnvConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load env config: %w", err)
+	}
+	preview.EnvScopes, err = renameInEnvConfig(config, oldName, newName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(preview.EnvScopes) > 0 {
+		envConfigPath, err := a.getEnvFilePath()
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := marshalEnvConfig(config)
+		if err != nil {
+			return nil, nil, err
+		}
+		writes[envConfigPath] = data
+	}
+
+	return preview, writes, nil
+}
+
+// PreviewRenameVariable lists the changes RenameVariable would make.
+func (a *App) PreviewRenameVariable(oldName string, newName string) ReturnValue {
+	preview, _, err := a.planRename(oldName, newName)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	return ReturnValue{Rename: preview}
+}
+
+// RenameVariable renames a variable in every .hurl file and env file of the
+// active workspace, in the user env config and in the secret vault. All files
+// are replaced together or not at all.
+func (a *App) RenameVariable(oldName string, newName string) ReturnValue {
+	preview, writes, err := a.planRename(oldName, newName)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	if len(writes) == 0 {
+		return ReturnValue{Error: fmt.Sprintf("variable %q is not used in the workspace", oldName)}
+	}
+	if err := writeFilesAtomic(writes, 0644); err != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to rename variable: %v", err)}
+	}
+	return ReturnValue{Rename: preview}
+}
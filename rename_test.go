@@ -0,0 +1,195 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenameInContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "template usages",
+			content: "GET http://{{host}}/{{ host }}/{{hostname}}\n",
+			want:    "GET http://{{server}}/{{ server }}/{{hostname}}\n",
+		},
+		{
+			name:    "capture definition and variable query",
+			content: "GET http://x\nHTTP 200\n[Captures]\nhost: header \"Location\"\nother: variable \"host\"\n",
+			want:    "GET http://x\nHTTP 200\n[Captures]\nserver: header \"Location\"\nother: variable \"server\"\n",
+		},
+		{
+			name:    "assert query",
+			content: "GET http://x\nHTTP 200\n[Asserts]\nvariable \"host\" exists\n",
+			want:    "GET http://x\nHTTP 200\n[Asserts]\nvariable \"server\" exists\n",
+		},
+		{
+			name:    "options variable",
+			content: "GET http://x\n[Options]\nvariable: host=localhost\n",
+			want:    "GET http://x\n[Options]\nvariable: server=localhost\n",
+		},
+		{
+			name:    "comments and other sections kept",
+			content: "# uses {{host}}\nGET http://x\n[Query]\nhost: 1\n",
+			want:    "# uses {{host}}\nGET http://x\n[Query]\nhost: 1\n",
+		},
+		{
+			name:    "line endings kept",
+			content: "GET http://{{host}}\r\nHTTP 200\r\n",
+			want:    "GET http://{{server}}\r\nHTTP 200\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := renameInContent(tt.content, "host", "server")
+			if got != tt.want {
+				t.Errorf("renameInContent =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+// newRenameApp returns an app with a workspace using host at every layer,
+// and apikey as a secret.
+func newRenameApp(t *testing.T) (*App, string) {
+	a, ws := newTrashApp(t)
+	err := a.saveEnvConfig(&EnvConfig{
+		Global:       map[string]EnvValue{"host": {Value: "localhost"}},
+		Environments: map[string]map[string]EnvValue{"dev": {}},
+		OAuth2: map[string]OAuth2Config{"dev": {
+			GrantType: OAuth2ClientCredentials,
+			TokenURL:  "http://{{host}}/token",
+			ClientID:  "studio",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := a.UnlockVault("passphrase"); result.Error != "" {
+		t.Fatalf("UnlockVault: %s", result.Error)
+	}
+	if result := a.SetSecret("", "apikey", "s3cret"); result.Error != "" {
+		t.Fatalf("SetSecret: %s", result.Error)
+	}
+
+	files := map[string]string{
+		".hurlstudio/env.json":       `{"environments": {"dev": {"host": "dev.local"}}}`,
+		".hurlstudio/staging.env":    "# staging\nhost=staging.local\n",
+		".hurlstudio/env.local.json": `{"global": {"host": "127.0.0.1"}}`,
+		"api/.hurlvars":              "  host=api.local\nport=8080\n",
+		"api/login.hurlvars":         "hostname=login.local\n",
+		"api/login.hurl":             "GET http://{{host}}/{{apikey}}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(ws, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a, ws
+}
+
+func TestRenameVariable(t *testing.T) {
+	a, ws := newRenameApp(t)
+	result := a.RenameVariable("host", "server")
+	if result.Error != "" {
+		t.Fatalf("RenameVariable: %s", result.Error)
+	}
+	if got := strings.Join(result.Rename.EnvScopes, ","); got != "global,dev" {
+		t.Errorf("env scopes = %s, want global,dev", got)
+	}
+	if len(result.Rename.EnvFiles) != 4 {
+		t.Errorf("env files = %+v, want 4", result.Rename.EnvFiles)
+	}
+
+	resolved, err := a.resolveVariables(filepath.Join(ws, "api", "login.hurl"), "dev", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := resolved["host"]; ok {
+		t.Errorf("host still defined by the %s layer", resolved["host"].Layer)
+	}
+	if v := resolved["server"]; v.Value != "api.local" || len(v.Overrides) != 3 {
+		t.Errorf("server = %+v, want api.local over 3 layers", v)
+	}
+	config, _ := a.loadEnvConfig()
+	if url := config.OAuth2["dev"].TokenURL; url != "http://{{server}}/token" {
+		t.Errorf("oauth2 token url = %s", url)
+	}
+	data, _ := os.ReadFile(filepath.Join(ws, ".hurlstudio", "staging.env"))
+	if string(data) != "# staging\nserver=staging.local\n" {
+		t.Errorf("staging.env = %q", data)
+	}
+	data, _ = os.ReadFile(filepath.Join(ws, "api", "login.hurlvars"))
+	if string(data) != "hostname=login.local\n" {
+		t.Errorf("login.hurlvars = %q", data)
+	}
+
+	// Secrets are sealed with their name: the renamed one still decrypts
+	if result := a.RenameVariable("apikey", "key"); result.Error != "" {
+		t.Fatalf("RenameVariable: %s", result.Error)
+	}
+	secrets, err := a.decryptSecrets("")
+	if err != nil {
+		t.Fatalf("decryptSecrets: %v", err)
+	}
+	if secrets["key"] != "s3cret" || len(secrets) != 1 {
+		t.Errorf("secrets = %v, want key", secrets)
+	}
+	data, _ = os.ReadFile(filepath.Join(ws, "api", "login.hurl"))
+	if string(data) != "GET http://{{server}}/{{key}}\n" {
+		t.Errorf("login.hurl = %q", data)
+	}
+}
+
+func TestRenameVariableRefused(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(a *App, ws string)
+		oldName string
+		wantErr string
+	}{
+		{
+			name: "new name taken in a layer",
+			setup: func(a *App, ws string) {
+				os.WriteFile(filepath.Join(ws, "api", ".hurlvars"), []byte("host=a\nserver=b\n"), 0644)
+			},
+			oldName: "host",
+			wantErr: `"server" already exists`,
+		},
+		{
+			name:    "locked vault",
+			setup:   func(a *App, ws string) { a.LockVault() },
+			oldName: "apikey",
+			wantErr: "vault is locked",
+		},
+		{
+			name:    "no workspace",
+			setup:   func(a *App, ws string) { a.preferences.ActiveWorkspace = "" },
+			oldName: "host",
+			wantErr: "no workspace",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, ws := newRenameApp(t)
+			tt.setup(a, ws)
+			login := filepath.Join(ws, "api", "login.hurl")
+			before, _ := os.ReadFile(login)
+
+			result := a.RenameVariable(tt.oldName, "server")
+			if !strings.Contains(result.Error, tt.wantErr) {
+				t.Fatalf("RenameVariable = %q, want %q", result.Error, tt.wantErr)
+			}
+			if after, _ := os.ReadFile(login); string(after) != string(before) {
+				t.Errorf("login.hurl changed by a refused rename: %q", after)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// The file bindings only accept paths inside the allowed roots, the roots of
+// the open workspaces: with none open, every path is refused. Paths are
+// compared once symlinks are resolved, so a link cannot lead outside. Opening
+// a workspace with the folder picker is how the user allows another folder.
+
+// Operations that ask for confirmation on a folder that is not empty, and
+// emptying the trash.
+const (
+	operationTrash      = "trash"
+	operationDelete     = "delete"
+	operationEmptyTrash = "emptyTrash"
+)
+
+// Error codes of ReturnValue.ErrorCode.
+const (
+	ErrCodeInvalidPath          = "invalid_path"
+	ErrCodeOutsideWorkspace     = "outside_workspace"
+	ErrCodeProtectedPath        = "protected_path"
+	ErrCodeConfirmationRequired = "confirmation_required"
+	ErrCodeInvalidConfirmation  = "invalid_confirmation"
+)
+
+const (
+	confirmationLifetime = 2 * time.Minute
+	// Entries counted for a confirmation, more are reported as this many
+	maxCountedEntries = 10000
+)
+
+// SandboxError is an error with one of the ErrCode* codes.
+type SandboxError struct {
+	Code    string
+	Path    string
+	Message string
+}
+
+func (e *SandboxError) Error() string {
+	return e.Message
+}
+
+// errorResult returns err, with its code when it is a SandboxError.
+func errorResult(err error) ReturnValue {
+	var sandboxErr *SandboxError
+	if errors.As(err, &sandboxErr) {
+		return ReturnValue{Error: sandboxErr.Message, ErrorCode: sandboxErr.Code}
+	}
+	return ReturnValue{Error: err.Error()}
+}
+
+// resolvePath returns the absolute path with symlinks resolved. The missing
+// part of a path that does not exist yet is kept as is.
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	missing := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(resolved, missing), nil
+		}
+		parent := filepath.Dir(path)
+		if parent == path || !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		missing = filepath.Join(filepath.Base(path), missing)
+		path = parent
+	}
+}
+
+// allowedRoots returns the resolved roots the file bindings may access.
+func (a *App) allowedRoots() []string {
+	resolved := make([]string, 0, len(a.preferences.OpenWorkspaces))
+	for _, root := range a.preferences.OpenWorkspaces {
+		if path, err := resolvePath(root); err == nil {
+			resolved = append(resolved, path)
+		}
+	}
+	return resolved
+}
+
+// sandboxPath checks that path is inside the allowed roots and returns it
+// cleaned. With entry set, path names the directory entry itself, to delete,
+// rename or move it: a symlink is not followed, and a root is refused.
+func (a *App) sandboxPath(path string, entry bool) (string, error) {
+	if path == "" {
+		return "", &SandboxError{Code: ErrCodeInvalidPath, Message: "path is empty"}
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", &SandboxError{Code: ErrCodeInvalidPath, Path: path, Message: fmt.Sprintf("invalid path: %v", err)}
+	}
+	var resolved string
+	if entry {
+		if resolved, err = resolvePath(filepath.Dir(path)); err == nil {
+			resolved = filepath.Join(resolved, filepath.Base(path))
+		}
+	} else {
+		resolved, err = resolvePath(path)
+	}
+	if err != nil {
+		return "", &SandboxError{Code: ErrCodeInvalidPath, Path: path, Message: fmt.Sprintf("invalid path: %v", err)}
+	}
+
+	for _, root := range a.allowedRoots() {
+		if !isWithin(root, resolved) {
+			continue
+		}
+		if entry && resolved == root {
+			return "", &SandboxError{Code: ErrCodeProtectedPath, Path: path, Message: fmt.Sprintf("cannot change the workspace root: %s", path)}
+		}
+		return path, nil
+	}
+	if len(a.preferences.OpenWorkspaces) == 0 {
+		return "", &SandboxError{Code: ErrCodeOutsideWorkspace, Path: path, Message: "no workspace is open, open a folder as a workspace first"}
+	}
+	return "", &SandboxError{Code: ErrCodeOutsideWorkspace, Path: path, Message: fmt.Sprintf("path is outside the workspace: %s", path)}
+}
+
+// sandboxStart checks where env files are looked up from for a binding. An
+// empty start looks up none but the user's own.
+func (a *App) sandboxStart(start string) (string, error) {
+	if start == "" {
+		return "", nil
+	}
+	return a.sandboxPath(start, false)
+}
+
+// ConfirmationRequest is returned with ErrCodeConfirmationRequired. Passing
+// Token to ConfirmOperation performs the operation.
+type ConfirmationRequest struct {
+	Token     string `json:"token"`
+	Operation string `json:"operation"`
+	Path      string `json:"path"`
+	// Entries is the number of files and folders affected, at most 10000,
+	// or the entries counted before reading the folder failed.
+	Entries int `json:"entries"`
+}
+
+type confirmation struct {
+	operation string
+	path      string
+	// items are the paths the confirmed operation applies to, when it is not
+	// path alone.
+	items   []string
+	expires time.Time
+}
+
+// confirmStore holds the operations waiting for confirmation. A token is
+// valid once, for a short time.
+type confirmStore struct {
+	mu      sync.Mutex
+	pending map[string]confirmation
+}
+
+func (c *confirmStore) issue(operation string, path string, items ...string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to create confirmation token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == nil {
+		c.pending = map[string]confirmation{}
+	}
+	now := time.Now()
+	for key, pending := range c.pending {
+		if now.After(pending.expires) {
+			delete(c.pending, key)
+		}
+	}
+	c.pending[token] = confirmation{operation: operation, path: path, items: items, expires: now.Add(confirmationLifetime)}
+	return token, nil
+}
+
+func (c *confirmStore) take(token string) (confirmation, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending, ok := c.pending[token]
+	delete(c.pending, token)
+	if !ok || time.Now().After(pending.expires) {
+		return confirmation{}, false
+	}
+	return pending, true
+}
+
+// countEntries counts the files and folders below dir, up to max. It fails
+// when dir cannot be read.
+func countEntries(dir string, max int) (int, error) {
+	count := 0
+	stop := errors.New("stop")
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if path == dir {
+			return err
+		}
+		count++
+		if count >= max {
+			return stop
+		}
+		return nil
+	})
+	if err != nil && err != stop {
+		return count, err
+	}
+	return count, nil
+}
+
+// requireConfirmation returns a ConfirmationRequest for operation when path
+// is a folder that is not empty, or that cannot be read to know.
+func (a *App) requireConfirmation(operation string, path string) (ReturnValue, bool) {
+	entries, err := countEntries(path, maxCountedEntries)
+	if err == nil && entries == 0 {
+		return ReturnValue{}, false
+	}
+	message := fmt.Sprintf("%s contains %d entries, confirm to %s it", path, entries, operation)
+	if err != nil {
+		message = fmt.Sprintf("cannot tell what %s contains (%v), confirm to %s it", path, err, operation)
+	}
+	token, err := a.confirmations.issue(operation, path)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}, true
+	}
+	return ReturnValue{
+		Error:     message,
+		ErrorCode: ErrCodeConfirmationRequired,
+		Confirmation: &ConfirmationRequest{
+			Token:     token,
+			Operation: operation,
+			Path:      path,
+			Entries:   entries,
+		},
+	}, true
+}
+
+// ConfirmOperation performs an operation that returned a confirmation token.
+func (a *App) ConfirmOperation(token string) ReturnValue {
+	pending, ok := a.confirmations.take(token)
+	if !ok {
+		return ReturnValue{Error: "the confirmation is invalid or has expired", ErrorCode: ErrCodeInvalidConfirmation}
+	}
+	switch pending.operation {
+	case operationTrash:
+		return a.deletePath(pending.path, false, true)
+	case operationDelete:
+		return a.deletePath(pending.path, true, true)
+	case operationEmptyTrash:
+		return a.emptyTrash(pending.items)
+	}
+	return ReturnValue{Error: fmt.Sprintf("unknown operation: %s", pending.operation), ErrorCode: ErrCodeInvalidConfirmation}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolvePath(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(dir, "real", "sub"), 0755)
+	os.Symlink(filepath.Join(dir, "real"), filepath.Join(dir, "link"))
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"existing", filepath.Join(dir, "real", "sub"), filepath.Join(dir, "real", "sub")},
+		{"through a link", filepath.Join(dir, "link", "sub"), filepath.Join(dir, "real", "sub")},
+		{"missing part kept", filepath.Join(dir, "link", "new", "file.hurl"), filepath.Join(dir, "real", "new", "file.hurl")},
+		{"dot dot cleaned", filepath.Join(dir, "real", "sub", "..", "..", "link"), filepath.Join(dir, "real")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolvePath(tt.path)
+			if err != nil {
+				t.Fatalf("resolvePath: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("resolvePath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSandboxPath(t *testing.T) {
+	a := newTestApp(t)
+	ws, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	os.MkdirAll(filepath.Join(ws, "api"), 0755)
+	os.WriteFile(filepath.Join(outside, "secret"), nil, 0600)
+	os.Symlink(outside, filepath.Join(ws, "escape"))
+	os.Symlink(filepath.Join(ws, "api"), filepath.Join(ws, "inner"))
+
+	// No workspace open: nothing is allowed, the home folder included
+	home, _ := os.UserHomeDir()
+	if _, err := a.sandboxPath(filepath.Join(home, ".ssh", "id_rsa"), false); !hasCode(err, ErrCodeOutsideWorkspace) {
+		t.Errorf("home file without workspace: err = %v, want %s", err, ErrCodeOutsideWorkspace)
+	}
+
+	a.preferences.OpenWorkspaces = []string{ws}
+	tests := []struct {
+		name  string
+		path  string
+		entry bool
+		code  string
+	}{
+		{name: "file in workspace", path: filepath.Join(ws, "api", "login.hurl")},
+		{name: "workspace root", path: ws},
+		{name: "link inside the workspace", path: filepath.Join(ws, "inner", "login.hurl")},
+		{name: "outside", path: filepath.Join(outside, "secret"), code: ErrCodeOutsideWorkspace},
+		{name: "dot dot out", path: filepath.Join(ws, "api", "..", "..", "secret"), code: ErrCodeOutsideWorkspace},
+		{name: "symlink escape", path: filepath.Join(ws, "escape", "secret"), code: ErrCodeOutsideWorkspace},
+		{name: "symlink to a folder outside", path: filepath.Join(ws, "escape"), code: ErrCodeOutsideWorkspace},
+		{name: "the link itself as an entry", path: filepath.Join(ws, "escape"), entry: true},
+		{name: "root refused as an entry", path: ws, entry: true, code: ErrCodeProtectedPath},
+		{name: "file system root", path: "/", code: ErrCodeOutsideWorkspace},
+		{name: "empty", path: "", code: ErrCodeInvalidPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.sandboxPath(tt.path, tt.entry)
+			if tt.code == "" {
+				if err != nil {
+					t.Errorf("sandboxPath(%q) = %v, want allowed", tt.path, err)
+				}
+				return
+			}
+			if !hasCode(err, tt.code) {
+				t.Errorf("sandboxPath(%q) = %v, want %s", tt.path, err, tt.code)
+			}
+		})
+	}
+}
+
+func hasCode(err error, code string) bool {
+	sandboxErr, ok := err.(*SandboxError)
+	return ok && sandboxErr.Code == code
+}
+
+func TestCheckWorkspaceRoot(t *testing.T) {
+	newTestApp(t) // temporary home
+	home, _ := os.UserHomeDir()
+	tests := []struct {
+		root    string
+		allowed bool
+	}{
+		{"/", false},
+		{home, false},
+		{filepath.Join(home, "."), false},
+		{filepath.Join(home, "project"), true},
+	}
+	for _, tt := range tests {
+		if err := checkWorkspaceRoot(tt.root); (err == nil) != tt.allowed {
+			t.Errorf("checkWorkspaceRoot(%q) = %v, want allowed=%v", tt.root, err, tt.allowed)
+		}
+	}
+}
+
+func TestConfirmStore(t *testing.T) {
+	var store confirmStore
+	token, err := store.issue(operationDelete, "/ws/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := store.issue(operationTrash, "/ws/old")
+	store.pending[expired] = confirmation{operation: operationTrash, path: "/ws/old", expires: time.Now().Add(-time.Second)}
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"valid", token, true},
+		{"used twice", token, false},
+		{"expired", expired, false},
+		{"unknown", "deadbeef", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		pending, ok := store.take(tt.token)
+		if ok != tt.ok {
+			t.Errorf("%s: take = %v, want %v", tt.name, ok, tt.ok)
+		}
+		if ok && (pending.operation != operationDelete || pending.path != "/ws/api") {
+			t.Errorf("%s: take = %+v", tt.name, pending)
+		}
+	}
+	if len(store.pending) != 0 {
+		t.Errorf("%d tokens left after take", len(store.pending))
+	}
+}
+
+func TestRequireConfirmation(t *testing.T) {
+	a := newTestApp(t)
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	full := filepath.Join(dir, "full")
+	os.MkdirAll(empty, 0755)
+	os.MkdirAll(filepath.Join(full, "sub"), 0755)
+	os.WriteFile(filepath.Join(full, "sub", "a.hurl"), nil, 0644)
+
+	tests := []struct {
+		name     string
+		path     string
+		required bool
+		entries  int
+	}{
+		{"empty folder", empty, false, 0},
+		{"folder with entries", full, true, 2},
+		// The count failing must not skip the confirmation
+		{"unreadable folder", filepath.Join(dir, "missing"), true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, required := a.requireConfirmation(operationDelete, tt.path)
+			if required != tt.required {
+				t.Fatalf("required = %v, want %v (%s)", required, tt.required, result.Error)
+			}
+			if !required {
+				return
+			}
+			if result.ErrorCode != ErrCodeConfirmationRequired || result.Confirmation.Entries != tt.entries {
+				t.Errorf("result = %+v, want a confirmation for %d entries", result, tt.entries)
+			}
+		})
+	}
+}
+
+func TestNavigateUp(t *testing.T) {
+	a, ws := newTrashApp(t)
+	os.MkdirAll(filepath.Join(ws, "api"), 0755)
+	if result := a.ChangeDirectory(filepath.Join(ws, "api")); result.Error != "" {
+		t.Fatal(result.Error)
+	}
+	if result := a.NavigateUp(); result.Error != "" || a.explorerState.CurrentDir.Path != ws {
+		t.Fatalf("NavigateUp = %q, current dir %s, want %s", result.Error, a.explorerState.CurrentDir.Path, ws)
+	}
+	if result := a.NavigateUp(); result.Error != "already at root directory" || a.explorerState.CurrentDir.Path != ws {
+		t.Errorf("NavigateUp at the workspace root = %q, current dir %s", result.Error, a.explorerState.CurrentDir.Path)
+	}
+}
+
+func TestSeedWorkspace(t *testing.T) {
+	a := newTestApp(t)
+	home := a.explorerState.CurrentDir.Path
+	project := filepath.Join(home, "project")
+	file := filepath.Join(project, "login.hurl")
+	os.MkdirAll(project, 0755)
+	os.WriteFile(file, nil, 0644)
+
+	tests := []struct {
+		name       string
+		prefs      Preferences
+		wantRoot   string
+		wantFile   string
+		workspaces int
+	}{
+		{name: "last folder", prefs: Preferences{LastOpenedDir: project, LastOpenedFile: file}, wantRoot: project, wantFile: file, workspaces: 1},
+		{name: "home refused, folder of the last file", prefs: Preferences{LastOpenedDir: home, LastOpenedFile: file}, wantRoot: project, wantFile: file, workspaces: 1},
+		{name: "home only", prefs: Preferences{LastOpenedDir: home}},
+		{name: "missing folder", prefs: Preferences{LastOpenedDir: filepath.Join(home, "gone")}},
+		{name: "workspaces already used", prefs: Preferences{LastOpenedDir: project, Workspaces: []Workspace{{Root: home}}}, workspaces: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a.preferences = tt.prefs
+			a.seedWorkspace()
+			if len(a.preferences.Workspaces) != tt.workspaces {
+				t.Fatalf("workspaces = %+v, want %d", a.preferences.Workspaces, tt.workspaces)
+			}
+			ws := a.activeWorkspace()
+			if tt.wantRoot == "" {
+				if ws != nil {
+					t.Errorf("active workspace = %+v, want none", ws)
+				}
+				return
+			}
+			if ws == nil || ws.Root != tt.wantRoot || ws.LastOpenedFile != tt.wantFile || !a.preferences.isOpen(tt.wantRoot) {
+				t.Errorf("active workspace = %+v, want %s with %s", ws, tt.wantRoot, tt.wantFile)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultSearchContext    = 2
+	defaultSearchMaxResults = 2000
+)
+
+// SearchOptions configure SearchWorkspace. Include and Exclude are globs
+// relative to the workspace root: a pattern without a slash matches a file
+// or folder name at any depth, "**" stands for any number of folders.
+// ContextLines defaults to 2 and MaxResults to 2000.
+type SearchOptions struct {
+	Regex         bool     `json:"regex"`
+	CaseSensitive bool     `json:"caseSensitive"`
+	WholeWord     bool     `json:"wholeWord"`
+	Include       []string `json:"include"`
+	Exclude       []string `json:"exclude"`
+	ContextLines  int      `json:"contextLines"`
+	MaxResults    int      `json:"maxResults"`
+}
+
+// SearchMatch is one match. Line and Column are 1-based, Column and Length
+// count bytes of Text, the whole line.
+type SearchMatch struct {
+	Line   int      `json:"line"`
+	Column int      `json:"column"`
+	Length int      `json:"length"`
+	Text   string   `json:"text"`
+	Before []string `json:"before"`
+	After  []string `json:"after"`
+}
+
+type SearchFileResult struct {
+	Path    string        `json:"path"`
+	RelPath string        `json:"relPath"`
+	Matches []SearchMatch `json:"matches"`
+}
+
+type SearchResult struct {
+	Files        []SearchFileResult `json:"files"`
+	TotalMatches int                `json:"totalMatches"`
+	// Truncated is set when the search stopped at MaxResults.
+	Truncated bool `json:"truncated"`
+}
+
+// ReplacePreview lists the lines ReplaceInWorkspace changes.
+type ReplacePreview struct {
+	Files        []RenameFileChange `json:"files"`
+	Replacements int                `json:"replacements"`
+}
+
+// searchPattern compiles the query with the options applied.
+func searchPattern(query string, options SearchOptions) (*regexp.Regexp, error) {
+	if query == "" {
+		return nil, fmt.Errorf("search query is empty")
+	}
+	expr := query
+	if !options.Regex {
+		expr = regexp.QuoteMeta(query)
+	}
+	if options.WholeWord {
+		expr = `\b(?:` + expr + `)\b`
+	}
+	if !options.CaseSensitive {
+		expr = `(?i)` + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+	return re, nil
+}
+
+// matchPathGlob reports whether rel, a slash separated path, or one of its
+// folders matches pattern.
+func matchPathGlob(pattern string, rel string) bool {
+	segments := strings.Split(rel, "/")
+	if !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+		pattern = strings.TrimSuffix(pattern, "/")
+		for _, segment := range segments {
+			if ok, _ := path.Match(pattern, segment); ok {
+				return true
+			}
+		}
+		return false
+	}
+	pattern = strings.Trim(pattern, "/")
+	for i := 1; i <= len(segments); i++ {
+		if matchGlobPath(pattern, strings.Join(segments[:i], "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyGlob(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if pattern != "" && matchPathGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// searchFiles returns the .hurl files of the workspace passing the globs,
+// with their path relative to the root.
+func (a *App) searchFiles(options SearchOptions) (map[string]string, []string, error) {
+	a.fileIndex.setRoot(a.indexRoot())
+	root, files := a.fileIndex.list()
+	if root == "" {
+		return nil, nil, fmt.Errorf("no workspace to search")
+	}
+	relPaths := map[string]string{}
+	var selected []string
+	for _, file := range files {
+		if !isHurlFile(file) {
+			continue
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if len(options.Include) > 0 && !matchesAnyGlob(options.Include, rel) {
+			continue
+		}
+		if matchesAnyGlob(options.Exclude, rel) {
+			continue
+		}
+		relPaths[file] = rel
+		selected = append(selected, file)
+	}
+	return relPaths, selected, nil
+}
+
+// splitLines splits content in lines without their \n or \r\n ending.
+func splitLines(content string) []string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+func contextLines(lines []string, from int, to int) []string {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(lines) {
+		to = len(lines)
+	}
+	if from >= to {
+		return []string{}
+	}
+	return append([]string{}, lines[from:to]...)
+}
+
+// SearchWorkspace searches the .hurl files of the workspace line by line and
+// returns the matches grouped by file.
+func (a *App) SearchWorkspace(query string, options SearchOptions) ReturnValue {
+	re, err := searchPattern(query, options)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	if options.ContextLines <= 0 {
+		options.ContextLines = defaultSearchContext
+	}
+	if options.MaxResults <= 0 {
+		options.MaxResults = defaultSearchMaxResults
+	}
+	relPaths, files, err := a.searchFiles(options)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+
+	result := &SearchResult{Files: []SearchFileResult{}}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		lines := splitLines(string(content))
+		fileResult := SearchFileResult{Path: file, RelPath: relPaths[file], Matches: []SearchMatch{}}
+		for i, line := range lines {
+			for _, loc := range re.FindAllStringIndex(line, -1) {
+				if result.TotalMatches >= options.MaxResults {
+					result.Truncated = true
+					break
+				}
+				// Skip empty matches of patterns like a*
+				if loc[0] == loc[1] {
+					continue
+				}
+				fileResult.Matches = append(fileResult.Matches, SearchMatch{
+					Line:   i + 1,
+					Column: loc[0] + 1,
+					Length: loc[1] - loc[0],
+					Text:   line,
+					Before: contextLines(lines, i-options.ContextLines, i),
+					After:  contextLines(lines, i+1, i+1+options.ContextLines),
+				})
+				result.TotalMatches++
+			}
+		}
+		if len(fileResult.Matches) > 0 {
+			result.Files = append(result.Files, fileResult)
+		}
+		if result.Truncated {
+			break
+		}
+	}
+	return ReturnValue{Search: result}
+}
+
+// planReplace computes the replacements without touching the disk. With
+// Regex set, the replacement can refer to groups as $1 or ${name}.
+func (a *App) planReplace(query string, replacement string, options SearchOptions) (*ReplacePreview, map[string][]byte, error) {
+	re, err := searchPattern(query, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, files, err := a.searchFiles(options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	preview := &ReplacePreview{Files: []RenameFileChange{}}
+	writes := map[string][]byte{}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		// Split on \n only so that each line keeps its own line ending
+		lines := strings.Split(string(content), "\n")
+		var edits []RenameEdit
+		for i, raw := range lines {
+			line := strings.TrimSuffix(raw, "\r")
+			count := len(re.FindAllStringIndex(line, -1))
+			if count == 0 {
+				continue
+			}
+			var replaced string
+			if options.Regex {
+				replaced = re.ReplaceAllString(line, replacement)
+			} else {
+				replaced = re.ReplaceAllLiteralString(line, replacement)
+			}
+			if replaced == line {
+				continue
+			}
+			edits = append(edits, RenameEdit{Line: i + 1, Before: line, After: replaced})
+			preview.Replacements += count
+			lines[i] = replaced + raw[len(line):]
+		}
+		if len(edits) == 0 {
+			continue
+		}
+		preview.Files = append(preview.Files, RenameFileChange{Path: file, Edits: edits})
+		writes[file] = []byte(strings.Join(lines, "\n"))
+	}
+	return preview, writes, nil
+}
+
+// PreviewReplaceInWorkspace lists the changes ReplaceInWorkspace would make.
+func (a *App) PreviewReplaceInWorkspace(query string, replacement string, options SearchOptions) ReturnValue {
+	preview, _, err := a.planReplace(query, replacement, options)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	return ReturnValue{Replace: preview}
+}
+
+// ReplaceInWorkspace replaces every match in the .hurl files of the workspace.
+// All files are replaced together or not at all.
+func (a *App) ReplaceInWorkspace(query string, replacement string, options SearchOptions) ReturnValue {
+	preview, writes, err := a.planReplace(query, replacement, options)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	if len(writes) == 0 {
+		return ReturnValue{Error: fmt.Sprintf("no match for %q in the workspace", query)}
+	}
+	if err := writeFilesAtomic(writes, 0644); err != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to replace: %v", err)}
+	}
+	return ReturnValue{Replace: preview}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newSearchApp(t *testing.T) (*App, string) {
+	a, ws := newTrashApp(t)
+	files := map[string]string{
+		"api/login.hurl":  "POST {{host}}/login\nHTTP 200\n[Captures]\ntoken: jsonpath \"$.token\"\n",
+		"api/users.hurl":  "GET {{host}}/users\r\nAuthorization: Bearer {{token}}\r\nHTTP 200\r\n",
+		"legacy/old.hurl": "GET {{host}}/tokens\n",
+		"notes.txt":       "token\n",
+	}
+	for rel, content := range files {
+		path := filepath.Join(ws, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a, ws
+}
+
+// searchHits flattens a search result to "relPath:line:column" entries.
+func searchHits(result *SearchResult) []string {
+	hits := []string{}
+	for _, file := range result.Files {
+		for _, m := range file.Matches {
+			hits = append(hits, fmt.Sprintf("%s:%d:%d", file.RelPath, m.Line, m.Column))
+		}
+	}
+	return hits
+}
+
+func TestSearchWorkspace(t *testing.T) {
+	a, _ := newSearchApp(t)
+	tests := []struct {
+		name    string
+		query   string
+		options SearchOptions
+		want    []string
+		wantErr string
+	}{
+		{
+			name:  "plain text ignores case and other files",
+			query: "TOKEN",
+			want:  []string{"api/login.hurl:4:1", "api/login.hurl:4:20", "api/users.hurl:2:25", "legacy/old.hurl:1:14"},
+		},
+		{
+			name:    "case sensitive",
+			query:   "TOKEN",
+			options: SearchOptions{CaseSensitive: true},
+			want:    []string{},
+		},
+		{
+			name:    "whole word",
+			query:   "token",
+			options: SearchOptions{WholeWord: true},
+			want:    []string{"api/login.hurl:4:1", "api/login.hurl:4:20", "api/users.hurl:2:25"},
+		},
+		{
+			name:    "regex",
+			query:   `^(GET|POST) \{\{host\}\}/\w+$`,
+			options: SearchOptions{Regex: true, CaseSensitive: true},
+			want:    []string{"api/login.hurl:1:1", "api/users.hurl:1:1", "legacy/old.hurl:1:1"},
+		},
+		{
+			name:    "metacharacters are literal without regex",
+			query:   "{{token}}",
+			options: SearchOptions{},
+			want:    []string{"api/users.hurl:2:23"},
+		},
+		{
+			name:    "include and exclude",
+			query:   "host",
+			options: SearchOptions{Include: []string{"*.hurl"}, Exclude: []string{"legacy"}},
+			want:    []string{"api/login.hurl:1:8", "api/users.hurl:1:7"},
+		},
+		{
+			name:    "max results",
+			query:   "token",
+			options: SearchOptions{MaxResults: 1},
+			want:    []string{"api/login.hurl:4:1"},
+		},
+		{
+			name:    "invalid regex",
+			query:   "(",
+			options: SearchOptions{Regex: true},
+			wantErr: "invalid regular expression",
+		},
+		{
+			name:    "empty query",
+			wantErr: "search query is empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := a.SearchWorkspace(tt.query, tt.options)
+			if tt.wantErr != "" {
+				if !strings.Contains(result.Error, tt.wantErr) {
+					t.Errorf("error = %q, want %q", result.Error, tt.wantErr)
+				}
+				return
+			}
+			if result.Error != "" {
+				t.Fatal(result.Error)
+			}
+			if got := searchHits(result.Search); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hits = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchWorkspaceContext(t *testing.T) {
+	a, _ := newSearchApp(t)
+	result := a.SearchWorkspace("Authorization", SearchOptions{ContextLines: 1})
+	if result.Error != "" {
+		t.Fatal(result.Error)
+	}
+	want := SearchMatch{
+		Line: 2, Column: 1, Length: 13,
+		Text:   "Authorization: Bearer {{token}}",
+		Before: []string{"GET {{host}}/users"},
+		After:  []string{"HTTP 200"},
+	}
+	if got := result.Search.Files[0].Matches; !reflect.DeepEqual(got, []SearchMatch{want}) {
+		t.Errorf("matches = %+v, want %+v", got, want)
+	}
+}
+
+func TestReplaceInWorkspace(t *testing.T) {
+	a, ws := newSearchApp(t)
+	users := filepath.Join(ws, "api", "users.hurl")
+
+	// The preview does not touch the disk
+	options := SearchOptions{Regex: true, CaseSensitive: true, Exclude: []string{"legacy"}}
+	result := a.PreviewReplaceInWorkspace(`\{\{(host)\}\}/(\w+)`, "{{${1}}}/v2/$2", options)
+	if result.Error != "" {
+		t.Fatal(result.Error)
+	}
+	if result.Replace.Replacements != 2 || len(result.Replace.Files) != 2 {
+		t.Fatalf("preview = %+v, want 2 replacements in 2 files", result.Replace)
+	}
+	edit := result.Replace.Files[1].Edits[0]
+	if edit.Line != 1 || edit.Before != "GET {{host}}/users" || edit.After != "GET {{host}}/v2/users" {
+		t.Errorf("edit = %+v", edit)
+	}
+	if data, _ := os.ReadFile(users); string(data) != "GET {{host}}/users\r\nAuthorization: Bearer {{token}}\r\nHTTP 200\r\n" {
+		t.Errorf("preview changed the file: %q", data)
+	}
+
+	if result := a.ReplaceInWorkspace(`\{\{(host)\}\}/(\w+)`, "{{${1}}}/v2/$2", options); result.Error != "" {
+		t.Fatal(result.Error)
+	}
+	// Line endings are kept
+	if data, _ := os.ReadFile(users); string(data) != "GET {{host}}/v2/users\r\nAuthorization: Bearer {{token}}\r\nHTTP 200\r\n" {
+		t.Errorf("users.hurl = %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(ws, "legacy", "old.hurl")); string(data) != "GET {{host}}/tokens\n" {
+		t.Errorf("excluded file changed: %q", data)
+	}
+
+	// Without Regex the replacement is literal
+	if result := a.ReplaceInWorkspace("Bearer", "$1", SearchOptions{}); result.Error != "" {
+		t.Fatal(result.Error)
+	}
+	if data, _ := os.ReadFile(users); !strings.Contains(string(data), "Authorization: $1 {{token}}") {
+		t.Errorf("users.hurl = %q", data)
+	}
+	if result := a.ReplaceInWorkspace("missing", "x", SearchOptions{}); result.Error == "" {
+		t.Error("ReplaceInWorkspace without a match did not fail")
+	}
+}
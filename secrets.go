@@ -0,0 +1,408 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Secret variables live in vault.json next to env.json. Names are stored in
+// clear so the UI can list them, each value is encrypted with AES-GCM using a
+// key derived from a passphrase with scrypt. The key is kept in memory once
+// the vault is unlocked and values are only decrypted when needed.
+
+const (
+	vaultVersion = 1
+	// Known plaintext used to check the passphrase on unlock.
+	vaultCheckValue = "hurlstudio-vault"
+)
+
+var errVaultLocked = errors.New("secret vault is locked")
+
+type vaultFile struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    string `json:"salt"`
+	Check   string `json:"check"`
+	// Secrets maps a scope ("global" or an environment name) to encrypted values.
+	Secrets map[string]map[string]string `json:"secrets"`
+}
+
+// SecretsInfo describes the vault without exposing any value.
+type SecretsInfo struct {
+	Exists   bool                `json:"exists"`
+	Unlocked bool                `json:"unlocked"`
+	Names    map[string][]string `json:"names"`
+}
+
+type secretVault struct {
+	mu  sync.Mutex
+	key []byte
+}
+
+func deriveVaultKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+func sealSecret(key []byte, aad string, plaintext string) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), []byte(aad))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func openSecret(key []byte, aad string, encoded string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("encrypted value is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// secretScope maps an env name to the vault scope, "" being the globals.
+func secretScope(envName string) string {
+	if envName == "" {
+		return globalScope
+	}
+	return envName
+}
+
+func (a *App) getVaultFilePath() (string, error) {
+	configDir, err := a.getConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "vault.json"), nil
+}
+
+// loadVaultFile returns nil when no vault has been created yet.
+func (a *App) loadVaultFile() (*vaultFile, error) {
+	path, err := a.getVaultFilePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault: %w", err)
+	}
+	var vf vaultFile
+	if err := json.Unmarshal(data, &vf); err != nil {
+		return nil, fmt.Errorf("failed to parse vault: %w", err)
+	}
+	if vf.Secrets == nil {
+		vf.Secrets = map[string]map[string]string{}
+	}
+	return &vf, nil
+}
+
+func (a *App) saveVaultFile(vf *vaultFile) error {
+	path, err := a.getVaultFilePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(vf, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal vault: %w", err)
+	}
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write vault: %w", err)
+	}
+	return nil
+}
+
+// restoreVaultFile writes back a vault loaded earlier, nil meaning there was none.
+func (a *App) restoreVaultFile(vf *vaultFile) error {
+	if vf != nil {
+		return a.saveVaultFile(vf)
+	}
+	path, err := a.getVaultFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove vault: %w", err)
+	}
+	return nil
+}
+
+func (a *App) vaultKey() []byte {
+	a.vault.mu.Lock()
+	defer a.vault.mu.Unlock()
+	return a.vault.key
+}
+
+// scopeSecretNames returns the names of the secrets of envName, "" being the globals.
+func (a *App) scopeSecretNames(envName string) []string {
+	vf, err := a.loadVaultFile()
+	if err != nil || vf == nil {
+		return nil
+	}
+	var names []string
+	for name := range vf.Secrets[secretScope(envName)] {
+		names = append(names, name)
+	}
+	return names
+}
+
+// decryptSecrets returns the decrypted secrets of one scope. It fails with
+// errVaultLocked when the scope has secrets and the vault is locked.
+func (a *App) decryptSecrets(envName string) (map[string]string, error) {
+	vf, err := a.loadVaultFile()
+	if err != nil {
+		return nil, err
+	}
+	scope := secretScope(envName)
+	if vf == nil || len(vf.Secrets[scope]) == 0 {
+		return map[string]string{}, nil
+	}
+	key := a.vaultKey()
+	if key == nil {
+		return nil, errVaultLocked
+	}
+	secrets := make(map[string]string, len(vf.Secrets[scope]))
+	for name, encrypted := range vf.Secrets[scope] {
+		value, err := openSecret(key, name, encrypted)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret %s: %w", name, err)
+		}
+		secrets[name] = value
+	}
+	return secrets, nil
+}
+
+func (a *App) secretsInfo() (*SecretsInfo, error) {
+	vf, err := a.loadVaultFile()
+	if err != nil {
+		return nil, err
+	}
+	info := &SecretsInfo{Names: map[string][]string{}}
+	if vf == nil {
+		return info, nil
+	}
+	info.Exists = true
+	info.Unlocked = a.vaultKey() != nil
+	for scope, secrets := range vf.Secrets {
+		names := make([]string, 0, len(secrets))
+		for name := range secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		info.Names[scope] = names
+	}
+	return info, nil
+}
+
+// copySecretScope copies the secrets of an environment to another one, removing
+// them from the source unless keep is set. A nil to deletes the scope.
+// Values are bound to their name only, so they move without being decrypted.
+func (a *App) copySecretScope(from string, to *string, keep bool) error {
+	vf, err := a.loadVaultFile()
+	if err != nil || vf == nil {
+		return err
+	}
+	secrets, ok := vf.Secrets[from]
+	if !ok {
+		return nil
+	}
+	if to != nil {
+		copied := make(map[string]string, len(secrets))
+		for name, encrypted := range secrets {
+			copied[name] = encrypted
+		}
+		vf.Secrets[*to] = copied
+	}
+	if !keep {
+		delete(vf.Secrets, from)
+	}
+	return a.saveVaultFile(vf)
+}
+
+// GetSecretsInfo returns whether the vault exists and is unlocked, and the
+// names of the secrets per scope. Values are never returned.
+func (a *App) GetSecretsInfo() ReturnValue {
+	info, err := a.secretsInfo()
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	return ReturnValue{Secrets: info}
+}
+
+// UnlockVault unlocks the vault for the rest of the session. The first call
+// creates the vault with the given passphrase.
+func (a *App) UnlockVault(passphrase string) ReturnValue {
+	if passphrase == "" {
+		return ReturnValue{Error: "passphrase is empty"}
+	}
+	vf, err := a.loadVaultFile()
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+
+	if vf == nil {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return ReturnValue{Error: fmt.Sprintf("failed to generate salt: %v", err)}
+		}
+		key, err := deriveVaultKey(passphrase, salt)
+		if err != nil {
+			return ReturnValue{Error: fmt.Sprintf("failed to derive key: %v", err)}
+		}
+		check, err := sealSecret(key, "check", vaultCheckValue)
+		if err != nil {
+			return ReturnValue{Error: fmt.Sprintf("failed to initialize vault: %v", err)}
+		}
+		vf = &vaultFile{
+			Version: vaultVersion,
+			KDF:     "scrypt",
+			Salt:    base64.StdEncoding.EncodeToString(salt),
+			Check:   check,
+			Secrets: map[string]map[string]string{},
+		}
+		if err := a.saveVaultFile(vf); err != nil {
+			return ReturnValue{Error: err.Error()}
+		}
+		a.vault.mu.Lock()
+		a.vault.key = key
+		a.vault.mu.Unlock()
+		return a.GetSecretsInfo()
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(vf.Salt)
+	if err != nil {
+		return ReturnValue{Error: fmt.Sprintf("invalid vault salt: %v", err)}
+	}
+	key, err := deriveVaultKey(passphrase, salt)
+	if err != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to derive key: %v", err)}
+	}
+	if check, err := openSecret(key, "check", vf.Check); err != nil || check != vaultCheckValue {
+		return ReturnValue{Error: "wrong passphrase"}
+	}
+	a.vault.mu.Lock()
+	a.vault.key = key
+	a.vault.mu.Unlock()
+	return a.GetSecretsInfo()
+}
+
+// LockVault forgets the vault key.
+func (a *App) LockVault() ReturnValue {
+	a.vault.mu.Lock()
+	a.vault.key = nil
+	a.vault.mu.Unlock()
+	return a.GetSecretsInfo()
+}
+
+// SetSecret adds or replaces a secret variable. An empty envName targets the globals.
+func (a *App) SetSecret(envName string, name string, value string) ReturnValue {
+	if err := validateVariableName(name); err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	key := a.vaultKey()
+	if key == nil {
+		return ReturnValue{Error: errVaultLocked.Error()}
+	}
+	vf, err := a.loadVaultFile()
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	if vf == nil {
+		return ReturnValue{Error: "vault does not exist"}
+	}
+	if envName != "" {
+		config, err := a.loadEnvConfig()
+		if err != nil {
+			return ReturnValue{Error: err.Error()}
+		}
+		if _, ok := config.Environments[envName]; !ok {
+			return ReturnValue{Error: fmt.Sprintf("environment does not exist: %s", envName)}
+		}
+	}
+
+	scope := secretScope(envName)
+	encrypted, err := sealSecret(key, name, value)
+	if err != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to encrypt secret: %v", err)}
+	}
+	if vf.Secrets[scope] == nil {
+		vf.Secrets[scope] = map[string]string{}
+	}
+	vf.Secrets[scope][name] = encrypted
+	if err := a.saveVaultFile(vf); err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	return a.GetSecretsInfo()
+}
+
+func (a *App) DeleteSecret(envName string, name string) ReturnValue {
+	vf, err := a.loadVaultFile()
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	scope := secretScope(envName)
+	if vf == nil || vf.Secrets[scope] == nil {
+		return ReturnValue{Error: fmt.Sprintf("secret does not exist: %s", name)}
+	}
+	if _, ok := vf.Secrets[scope][name]; !ok {
+		return ReturnValue{Error: fmt.Sprintf("secret does not exist: %s", name)}
+	}
+	delete(vf.Secrets[scope], name)
+	if len(vf.Secrets[scope]) == 0 {
+		delete(vf.Secrets, scope)
+	}
+	if err := a.saveVaultFile(vf); err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	return a.GetSecretsInfo()
+}
+
+// RevealSecret returns the clear value of one secret, on explicit user request.
+func (a *App) RevealSecret(envName string, name string) ReturnValue {
+	secrets, err := a.decryptSecrets(envName)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	value, ok := secrets[name]
+	if !ok {
+		return ReturnValue{Error: fmt.Sprintf("secret does not exist: %s", name)}
+	}
+	return ReturnValue{SecretValue: value}
+}
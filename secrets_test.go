@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSealOpenSecret(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	key, err := deriveVaultKey("right passphrase", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongKey, err := deriveVaultKey("wrong passphrase", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := sealSecret(key, "token", "s3cret")
+	if err != nil {
+		t.Fatalf("sealSecret: %v", err)
+	}
+	again, _ := sealSecret(key, "token", "s3cret")
+	if again == sealed {
+		t.Error("sealing twice gave the same value, the nonce is not random")
+	}
+
+	tests := []struct {
+		name    string
+		key     []byte
+		aad     string
+		sealed  string
+		wantErr bool
+	}{
+		{name: "right key and name", key: key, aad: "token", sealed: sealed},
+		{name: "wrong passphrase", key: wrongKey, aad: "token", sealed: sealed, wantErr: true},
+		{name: "wrong name", key: key, aad: "password", sealed: sealed, wantErr: true},
+		{name: "tampered", key: key, aad: "token", sealed: sealed[:len(sealed)-4] + "AAAA", wantErr: true},
+		{name: "too short", key: key, aad: "token", sealed: "AAAA", wantErr: true},
+		{name: "not base64", key: key, aad: "token", sealed: "not base64!", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := openSecret(tt.key, tt.aad, tt.sealed)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("openSecret = %q, want an error", value)
+				}
+				return
+			}
+			if err != nil || value != "s3cret" {
+				t.Errorf("openSecret = %q, %v, want s3cret", value, err)
+			}
+		})
+	}
+}
+
+func TestVault(t *testing.T) {
+	a := newTestApp(t)
+	if result := a.UnlockVault("passphrase"); result.Error != "" || !result.Secrets.Unlocked {
+		t.Fatalf("UnlockVault = %+v, want a new unlocked vault", result)
+	}
+	if result := a.SetSecret("", "token", "s3cret"); result.Error != "" {
+		t.Fatalf("SetSecret: %s", result.Error)
+	}
+	if result := a.SetSecret("", "bad name", "x"); result.Error == "" {
+		t.Error("SetSecret accepted an invalid name")
+	}
+	if result := a.SetSecret("missing", "token", "x"); !strings.Contains(result.Error, "environment does not exist") {
+		t.Errorf("SetSecret in a missing environment = %q", result.Error)
+	}
+
+	// Values are never stored in clear
+	path, _ := a.getVaultFilePath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") {
+		t.Errorf("vault holds the value in clear: %s", data)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("vault mode = %v, %v, want 600", info.Mode().Perm(), err)
+	}
+
+	a.LockVault()
+	if _, err := a.decryptSecrets(""); err != errVaultLocked {
+		t.Errorf("decryptSecrets on a locked vault = %v, want %v", err, errVaultLocked)
+	}
+	if result := a.UnlockVault("wrong"); result.Error != "wrong passphrase" {
+		t.Errorf("UnlockVault with a wrong passphrase = %q", result.Error)
+	}
+	if result := a.UnlockVault("passphrase"); result.Error != "" {
+		t.Fatalf("UnlockVault: %s", result.Error)
+	}
+
+	// A value moved to another name no longer decrypts
+	var vf vaultFile
+	json.Unmarshal(data, &vf)
+	vf.Secrets[globalScope]["other"] = vf.Secrets[globalScope]["token"]
+	if err := a.saveVaultFile(&vf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.decryptSecrets(""); err == nil || !strings.Contains(err.Error(), "other") {
+		t.Errorf("decryptSecrets = %v, want other to fail", err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+//go:embed templates/*.hurl
+var builtinTemplates embed.FS
+
+const (
+	TemplateSourceBuiltin = "builtin"
+	TemplateSourceUser    = "user"
+	TemplateSourceProject = "project"
+)
+
+// ${name} or ${name:default}
+var templatePlaceholderRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::([^}]*))?\}`)
+
+type TemplatePlaceholder struct {
+	Name    string `json:"name"`
+	Default string `json:"default,omitempty"`
+}
+
+// FileTemplate is a .hurl file used as a starting point for new files.
+// ID is "<source>:<name>", for example "builtin:rest-crud".
+type FileTemplate struct {
+	ID           string                `json:"id"`
+	Name         string                `json:"name"`
+	Source       string                `json:"source"`
+	Description  string                `json:"description,omitempty"`
+	Content      string                `json:"content"`
+	Placeholders []TemplatePlaceholder `json:"placeholders"`
+}
+
+func newFileTemplate(source string, fileName string, content string) FileTemplate {
+	name := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	t := FileTemplate{
+		ID:           source + ":" + name,
+		Name:         name,
+		Source:       source,
+		Content:      content,
+		Placeholders: []TemplatePlaceholder{},
+	}
+
+	// The first comment line describes the template
+	first, _, _ := strings.Cut(content, "\n")
+	if strings.HasPrefix(first, "#") {
+		t.Description = strings.TrimSpace(strings.TrimPrefix(first, "#"))
+	}
+
+	seen := map[string]bool{}
+	for _, m := range templatePlaceholderRe.FindAllStringSubmatch(content, -1) {
+		if seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		t.Placeholders = append(t.Placeholders, TemplatePlaceholder{Name: m[1], Default: m[2]})
+	}
+	return t
+}
+
+// render substitutes the placeholders. Missing values fall back to the
+// placeholder default, then to an empty string.
+func (t FileTemplate) render(values map[string]string) string {
+	return templatePlaceholderRe.ReplaceAllStringFunc(t.Content, func(match string) string {
+		m := templatePlaceholderRe.FindStringSubmatch(match)
+		if v, ok := values[m[1]]; ok && v != "" {
+			return v
+		}
+		return m[2]
+	})
+}
+
+func templatesInDir(source string, dir string) []FileTemplate {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var templates []FileTemplate
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".hurl") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			fmt.Printf("Failed to read template %s: %v\n", entry.Name(), err)
+			continue
+		}
+		templates = append(templates, newFileTemplate(source, entry.Name(), string(content)))
+	}
+	return templates
+}
+
+func (a *App) loadTemplates() []FileTemplate {
+	var templates []FileTemplate
+
+	entries, _ := builtinTemplates.ReadDir("templates")
+	for _, entry := range entries {
+		content, err := builtinTemplates.ReadFile("templates/" + entry.Name())
+		if err != nil {
+			continue
+		}
+		templates = append(templates, newFileTemplate(TemplateSourceBuiltin, entry.Name(), string(content)))
+	}
+
+	if configDir, err := a.getConfigDir(); err == nil {
+		templates = append(templates, templatesInDir(TemplateSourceUser, filepath.Join(configDir, "templates"))...)
+	}
+	if root := findProjectRoot(a.workspaceRoot()); root != "" {
+		templates = append(templates, templatesInDir(TemplateSourceProject, filepath.Join(root, projectConfigDirName, "templates"))...)
+	}
+	return templates
+}
+
+// GetTemplates returns the built-in templates, the user templates from the
+// config dir and the templates of the project in .hurlstudio/templates.
+func (a *App) GetTemplates() ReturnValue {
+	return ReturnValue{Templates: a.loadTemplates()}
+}
+
+// CreateFileFromTemplate creates fileName in the current directory from a
+// template, substituting its placeholders with values.
+func (a *App) CreateFileFromTemplate(fileName string, templateID string, values map[string]string) ReturnValue {
+	for _, t := range a.loadTemplates() {
+		if t.ID == templateID {
+			return a.CreateNewFile(fileName, t.render(values))
+		}
+	}
+	return ReturnValue{Error: fmt.Sprintf("template not found: %s", templateID)}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// PathTransfer is a file or folder moved or copied from From to To.
+type PathTransfer struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// uniquePath returns dir/name, or "name copy", "name copy 2"... when taken.
+func uniquePath(dir string, name string, isDir bool) string {
+	target := filepath.Join(dir, name)
+	if _, err := os.Lstat(target); os.IsNotExist(err) {
+		return target
+	}
+	ext := ""
+	if !isDir {
+		ext = filepath.Ext(name)
+	}
+	stem := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		suffix := " copy"
+		if i > 1 {
+			suffix += " " + strconv.Itoa(i)
+		}
+		target = filepath.Join(dir, stem+suffix+ext)
+		if _, err := os.Lstat(target); os.IsNotExist(err) {
+			return target
+		}
+	}
+}
+
+// movedPath returns where path is after oldPath moved to newPath.
+func movedPath(path string, oldPath string, newPath string) (string, bool) {
+	if path == oldPath {
+		return newPath, true
+	}
+	if strings.HasPrefix(path, oldPath+string(filepath.Separator)) {
+		return newPath + path[len(oldPath):], true
+	}
+	return path, false
+}
+
+// copyTree copies a file, a symlink or a folder with its content to target.
+func copyTree(src string, target string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, target)
+	case info.IsDir():
+		if err := os.Mkdir(target, info.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := copyTree(filepath.Join(src, entry.Name()), filepath.Join(target, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return copyFile(src, target, info)
+	}
+}
+
+func copyFile(src string, target string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(target)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(target)
+		return err
+	}
+	return os.Chtimes(target, info.ModTime(), info.ModTime())
+}
+
+// movePath renames src to target, copying then removing it when they are on
+// different file systems.
+func movePath(src string, target string) error {
+	err := os.Rename(src, target)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyTree(src, target); err != nil {
+		os.RemoveAll(target)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// moveCache moves the cached results of src, a file or a folder, to follow
+// it to target, or copies them when keep is set. The results are only a cache,
+// so failing is not an error.
+func moveCache(src string, target string, keep bool) {
+	from := tempOutputPathFor(src)
+	if _, err := os.Stat(from); err != nil {
+		return
+	}
+	to := tempOutputPathFor(target)
+	// Results left by a file deleted at target would be mixed in otherwise
+	_ = os.RemoveAll(to)
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		fmt.Printf("failed to migrate cached results of %s: %v\n", src, err)
+		return
+	}
+	if keep {
+		if err := copyTree(from, to); err != nil {
+			fmt.Printf("failed to copy cached results of %s: %v\n", src, err)
+		}
+		return
+	}
+	if err := movePath(from, to); err != nil {
+		fmt.Printf("failed to move cached results of %s: %v\n", src, err)
+	}
+}
+
+// pathMoved updates the explorer state, the preferences and the workspaces
+// after oldPath moved to newPath.
+func (a *App) pathMoved(oldPath string, newPath string) {
+	move := func(path *string) {
+		*path, _ = movedPath(*path, oldPath, newPath)
+	}
+	moveAll := func(paths []string) {
+		for i := range paths {
+			move(&paths[i])
+		}
+	}
+
+	if path, ok := movedPath(a.explorerState.SelectedFile.Path, oldPath, newPath); ok {
+		a.explorerState.SelectedFile.Path = path
+		a.explorerState.SelectedFile.Name = filepath.Base(path)
+	}
+	if path, ok := movedPath(a.explorerState.CurrentDir.Path, oldPath, newPath); ok {
+		a.explorerState.CurrentDir.Path = path
+		a.explorerState.CurrentDir.Name = filepath.Base(path)
+	}
+	move(&a.preferences.LastOpenedFile)
+	move(&a.preferences.LastOpenedDir)
+	moveAll(a.preferences.RecentFiles)
+	// A workspace moves with its root folder
+	move(&a.preferences.ActiveWorkspace)
+	moveAll(a.preferences.OpenWorkspaces)
+	moveAll(a.preferences.RecentWorkspaces)
+	for i := range a.preferences.Workspaces {
+		ws := &a.preferences.Workspaces[i]
+		move(&ws.Root)
+		move(&ws.LastOpenedFile)
+		move(&ws.LastOpenedDir)
+		moveAll(ws.RecentFiles)
+	}
+	a.syncWatchers()
+}
+
+// checkTransfer validates moving or copying src into destDir.
+func (a *App) checkTransfer(src string, destDir string) (string, os.FileInfo, error) {
+	src, err := a.sandboxPath(src, true)
+	if err != nil {
+		return "", nil, err
+	}
+	info, err := os.Lstat(src)
+	if err != nil {
+		return "", nil, fmt.Errorf("path does not exist: %w", err)
+	}
+	if info.IsDir() && isWithin(src, destDir) {
+		return "", nil, fmt.Errorf("cannot put a folder inside itself: %s", src)
+	}
+	return src, info, nil
+}
+
+func (a *App) checkDestDir(destDir string) (string, error) {
+	destDir, err := a.sandboxPath(destDir, false)
+	if err != nil {
+		return "", err
+	}
+	stat, err := os.Stat(destDir)
+	if err != nil {
+		return "", fmt.Errorf("destination does not exist: %w", err)
+	}
+	if !stat.IsDir() {
+		return "", fmt.Errorf("destination is not a directory: %s", destDir)
+	}
+	return destDir, nil
+}
+
+// transferResult saves the preferences after a move or copy. The transfers
+// done before an error are returned with it.
+func (a *App) transferResult(transfers []PathTransfer, err error) ReturnValue {
+	if saveErr := a.savePreferences(); saveErr != nil {
+		fmt.Printf("failed to save preferences after move: %v\n", saveErr)
+	}
+	result := ReturnValue{FileExplorer: a.explorerState, Transfers: transfers}
+	if err != nil {
+		failed := errorResult(err)
+		result.Error, result.ErrorCode = failed.Error, failed.ErrorCode
+	}
+	return result
+}
+
+// MovePaths moves files and folders into destDir, along with their cached
+// results. A name already taken in destDir gets a " copy" suffix.
+func (a *App) MovePaths(paths []string, destDir string) ReturnValue {
+	destDir, err := a.checkDestDir(destDir)
+	if err != nil {
+		return errorResult(err)
+	}
+	transfers := []PathTransfer{}
+	for _, src := range paths {
+		src, info, err := a.checkTransfer(src, destDir)
+		if err != nil {
+			return a.transferResult(transfers, err)
+		}
+		if filepath.Dir(src) == destDir {
+			continue
+		}
+		target := uniquePath(destDir, filepath.Base(src), info.IsDir())
+		if err := movePath(src, target); err != nil {
+			return a.transferResult(transfers, fmt.Errorf("failed to move %s: %w", src, err))
+		}
+		moveCache(src, target, false)
+		a.pathMoved(src, target)
+		transfers = append(transfers, PathTransfer{From: src, To: target})
+	}
+	return a.transferResult(transfers, nil)
+}
+
+// CopyPaths copies files and folders into destDir, along with their cached
+// results. A name already taken in destDir gets a " copy" suffix.
+func (a *App) CopyPaths(paths []string, destDir string) ReturnValue {
+	destDir, err := a.checkDestDir(destDir)
+	if err != nil {
+		return errorResult(err)
+	}
+	transfers := []PathTransfer{}
+	for _, src := range paths {
+		src, info, err := a.checkTransfer(src, destDir)
+		if err != nil {
+			return a.transferResult(transfers, err)
+		}
+		target := uniquePath(destDir, filepath.Base(src), info.IsDir())
+		if err := copyTree(src, target); err != nil {
+			os.RemoveAll(target)
+			return a.transferResult(transfers, fmt.Errorf("failed to copy %s: %w", src, err))
+		}
+		moveCache(src, target, true)
+		transfers = append(transfers, PathTransfer{From: src, To: target})
+	}
+	return a.transferResult(transfers, nil)
+}
+
+// DuplicatePath copies a file or folder next to itself as "name copy". A
+// duplicated file becomes the selected file.
+func (a *App) DuplicatePath(path string) ReturnValue {
+	result := a.CopyPaths([]string{path}, filepath.Dir(path))
+	if result.Error != "" || len(result.Transfers) == 0 {
+		return result
+	}
+	if fi, err := createFileInfo(result.Transfers[0].To); err == nil && !fi.IsDir {
+		a.setCurrentFile(fi)
+		result.FileExplorer = a.explorerState
+	}
+	return result
+}
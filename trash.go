@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Deleted files go to the trash of the desktop, following the freedesktop.org
+// Trash specification: the file is moved to <trash>/files and described by
+// <trash>/info/<name>.trashinfo. Files of another file system than the home
+// trash go to the trash at the top of their own, $topdir/.Trash/$uid or
+// $topdir/.Trash-$uid. When those trashes are not available, on macOS and
+// Windows or when moving there fails, the app keeps its own trash with the
+// same layout in its config folder. Files are only ever renamed into a trash,
+// never copied: a file no trash of its file system accepts is not trashed.
+// Only the items deleted from the allowed roots are listed, the trash of the
+// desktop holding every deleted file of the user. Trashed files keep their
+// cached results until the trash is emptied.
+
+const trashInfoDateLayout = "2006-01-02T15:04:05"
+
+// TrashItem is a deleted file or folder. TrashPath, its location in the
+// trash, identifies it for RestoreFromTrash.
+type TrashItem struct {
+	TrashPath    string `json:"trashPath"`
+	Name         string `json:"name"`
+	OriginalPath string `json:"originalPath"`
+	DeletedAt    string `json:"deletedAt"`
+	IsDir        bool   `json:"isDir"`
+	Size         int64  `json:"size"`
+}
+
+// homeTrashDir returns $XDG_DATA_HOME/Trash, the trash of the desktop.
+func homeTrashDir() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get user home directory: %w", err)
+		}
+		dataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(dataHome, "Trash"), nil
+}
+
+// mountTopdir returns the top folder of the file system holding dir and its
+// device.
+func mountTopdir(dir string) (string, uint64, error) {
+	dev, err := deviceID(dir)
+	if err != nil {
+		return "", 0, err
+	}
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir, dev, nil
+		}
+		if parentDev, err := deviceID(parent); err != nil || parentDev != dev {
+			return dir, dev, nil
+		}
+		dir = parent
+	}
+}
+
+// topdirTrashes returns the trashes of the file system holding dir when it is
+// not the one of the home trash: $topdir/.Trash/$uid when $topdir/.Trash is a
+// sticky folder and not a link, as the specification requires, then
+// $topdir/.Trash-$uid.
+func topdirTrashes(dir string) []string {
+	home, err := homeTrashDir()
+	if err != nil {
+		return nil
+	}
+	// The home trash may not exist yet, its closest existing parent tells
+	for {
+		if _, err := os.Stat(home); err == nil || filepath.Dir(home) == home {
+			break
+		}
+		home = filepath.Dir(home)
+	}
+	homeDev, err := deviceID(home)
+	if err != nil {
+		return nil
+	}
+	topdir, dev, err := mountTopdir(dir)
+	if err != nil || dev == homeDev {
+		return nil
+	}
+
+	uid := strconv.Itoa(os.Getuid())
+	var dirs []string
+	shared := filepath.Join(topdir, ".Trash")
+	if info, err := os.Lstat(shared); err == nil && info.IsDir() && info.Mode()&os.ModeSticky != 0 {
+		dirs = append(dirs, filepath.Join(shared, uid))
+	}
+	return append(dirs, filepath.Join(topdir, ".Trash-"+uid))
+}
+
+// trashDirs returns the trashes for items deleted from the folders dirs, in
+// order of preference.
+func (a *App) trashDirs(dirs ...string) []string {
+	var trashes []string
+	seen := map[string]bool{}
+	add := func(trash string) {
+		if !seen[trash] {
+			seen[trash] = true
+			trashes = append(trashes, trash)
+		}
+	}
+	if goruntime.GOOS != "windows" && goruntime.GOOS != "darwin" {
+		if trash, err := homeTrashDir(); err == nil {
+			add(trash)
+		}
+		for _, dir := range dirs {
+			for _, trash := range topdirTrashes(dir) {
+				add(trash)
+			}
+		}
+	}
+	if configDir, err := a.getConfigDir(); err == nil {
+		add(filepath.Join(configDir, "trash"))
+	}
+	return trashes
+}
+
+// encodeTrashPath percent-encodes path as the Path key of a .trashinfo file.
+func encodeTrashPath(path string) string {
+	segments := strings.Split(filepath.ToSlash(path), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func formatTrashInfo(path string, deletedAt time.Time) string {
+	return fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		encodeTrashPath(path), deletedAt.Format(trashInfoDateLayout))
+}
+
+// parseTrashInfo returns the original path and the deletion date of a
+// .trashinfo file.
+func parseTrashInfo(infoPath string) (string, time.Time, error) {
+	f, err := os.Open(infoPath)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer f.Close()
+
+	var path string
+	var deletedAt time.Time
+	inSection := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inSection = line == "[Trash Info]"
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !inSection || !ok {
+			continue
+		}
+		switch key {
+		case "Path":
+			decoded, err := url.PathUnescape(value)
+			if err != nil {
+				return "", time.Time{}, fmt.Errorf("invalid path in %s: %w", infoPath, err)
+			}
+			path = filepath.FromSlash(decoded)
+		case "DeletionDate":
+			deletedAt, _ = time.ParseInLocation(trashInfoDateLayout, value, time.Local)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", time.Time{}, err
+	}
+	if !filepath.IsAbs(path) {
+		return "", time.Time{}, fmt.Errorf("no absolute path in %s", infoPath)
+	}
+	return path, deletedAt, nil
+}
+
+// moveToTrash moves path to trashDir and returns its location in the trash.
+// The .trashinfo file is created first and exclusively, which reserves the
+// name in the trash. Path is renamed, so a trash on another file system fails.
+func moveToTrash(trashDir string, path string) (string, error) {
+	filesDir := filepath.Join(trashDir, "files")
+	infoDir := filepath.Join(trashDir, "info")
+	for _, dir := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", fmt.Errorf("failed to create trash folder: %w", err)
+		}
+	}
+
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	for i := 1; ; i++ {
+		name := base
+		if i > 1 {
+			name = strings.TrimSuffix(base, ext) + "." + strconv.Itoa(i) + ext
+		}
+		infoPath := filepath.Join(infoDir, name+".trashinfo")
+		info, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create trash info: %w", err)
+		}
+		_, err = info.WriteString(formatTrashInfo(path, time.Now()))
+		if closeErr := info.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(infoPath)
+			return "", fmt.Errorf("failed to write trash info: %w", err)
+		}
+
+		trashPath := filepath.Join(filesDir, name)
+		if _, err := os.Lstat(trashPath); err == nil {
+			// Left over without its info file, keep it and try another name
+			os.Remove(infoPath)
+			continue
+		}
+		if err := os.Rename(path, trashPath); err != nil {
+			os.Remove(infoPath)
+			return "", fmt.Errorf("failed to move to the trash: %w", err)
+		}
+		return trashPath, nil
+	}
+}
+
+// trash moves path to the first trash that accepts it.
+func (a *App) trash(path string) (string, error) {
+	err := fmt.Errorf("no trash available")
+	for _, dir := range a.trashDirs(filepath.Dir(path)) {
+		var trashPath string
+		if trashPath, err = moveToTrash(dir, path); err == nil {
+			return trashPath, nil
+		}
+	}
+	return "", err
+}
+
+// trashInfoPath returns the .trashinfo file of an item of the trashes of the
+// allowed roots, or an error when trashPath is not one.
+func (a *App) trashInfoPath(trashPath string) (string, error) {
+	trashPath = filepath.Clean(trashPath)
+	for _, dir := range a.trashDirs(a.allowedRoots()...) {
+		if filepath.Dir(trashPath) == filepath.Join(dir, "files") {
+			return filepath.Join(dir, "info", filepath.Base(trashPath)+".trashinfo"), nil
+		}
+	}
+	return "", fmt.Errorf("not in the trash: %s", trashPath)
+}
+
+func listTrash(trashDir string) []TrashItem {
+	infoPaths, _ := filepath.Glob(filepath.Join(trashDir, "info", "*.trashinfo"))
+	var items []TrashItem
+	for _, infoPath := range infoPaths {
+		originalPath, deletedAt, err := parseTrashInfo(infoPath)
+		if err != nil {
+			continue
+		}
+		trashPath := filepath.Join(trashDir, "files", strings.TrimSuffix(filepath.Base(infoPath), ".trashinfo"))
+		info, err := os.Lstat(trashPath)
+		if err != nil {
+			continue
+		}
+		items = append(items, TrashItem{
+			TrashPath:    trashPath,
+			Name:         filepath.Base(originalPath),
+			OriginalPath: originalPath,
+			DeletedAt:    deletedAt.Format("2006-01-02 15:04:05"),
+			IsDir:        info.IsDir(),
+			Size:         info.Size(),
+		})
+	}
+	return items
+}
+
+// trashItems returns the items of the trashes deleted from the allowed roots.
+func (a *App) trashItems() []TrashItem {
+	roots := a.allowedRoots()
+	items := []TrashItem{}
+	for _, dir := range a.trashDirs(roots...) {
+		for _, item := range listTrash(dir) {
+			for _, root := range roots {
+				if isWithin(root, item.OriginalPath) {
+					items = append(items, item)
+					break
+				}
+			}
+		}
+	}
+	return items
+}
+
+// ListTrash returns the items of the trash deleted from the open workspaces,
+// most recently deleted first.
+func (a *App) ListTrash() ReturnValue {
+	items := a.trashItems()
+	// The dates have the same layout, so they sort as strings
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].DeletedAt > items[j].DeletedAt
+	})
+	return ReturnValue{Trash: items}
+}
+
+// EmptyTrash asks for confirmation, then deletes the items ListTrash returns
+// for good, with their cached results. Only the items listed when asking are
+// deleted, not those trashed before the confirmation.
+func (a *App) EmptyTrash() ReturnValue {
+	items := a.trashItems()
+	if len(items) == 0 {
+		return ReturnValue{Trash: items}
+	}
+	trashPaths := make([]string, len(items))
+	for i, item := range items {
+		trashPaths[i] = item.TrashPath
+	}
+	token, err := a.confirmations.issue(operationEmptyTrash, "", trashPaths...)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	return ReturnValue{
+		Error:     fmt.Sprintf("the trash contains %d items, confirm to delete them for good", len(items)),
+		ErrorCode: ErrCodeConfirmationRequired,
+		Confirmation: &ConfirmationRequest{
+			Token:     token,
+			Operation: operationEmptyTrash,
+			Entries:   len(items),
+		},
+	}
+}
+
+// emptyTrash deletes the confirmed items still in the trash. The cached
+// results of an item go with it, unless its original path exists again and
+// owns them.
+func (a *App) emptyTrash(trashPaths []string) ReturnValue {
+	for _, trashPath := range trashPaths {
+		infoPath, err := a.trashInfoPath(trashPath)
+		if err != nil {
+			continue
+		}
+		originalPath, _, err := parseTrashInfo(infoPath)
+		if err != nil {
+			// Restored meanwhile
+			continue
+		}
+		if err := os.RemoveAll(trashPath); err != nil {
+			return ReturnValue{Error: fmt.Sprintf("failed to empty trash: %v", err), Trash: a.trashItems()}
+		}
+		os.Remove(infoPath)
+		if _, err := os.Lstat(originalPath); os.IsNotExist(err) {
+			_ = os.RemoveAll(tempOutputPathFor(originalPath))
+		}
+	}
+	return a.ListTrash()
+}
+
+// RestoreFromTrash moves an item of the trash back to where it was deleted
+// from, recreating its folder if needed. When that path is taken again, the
+// item gets a " copy" suffix.
+func (a *App) RestoreFromTrash(trashPath string) ReturnValue {
+	infoPath, err := a.trashInfoPath(trashPath)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	originalPath, _, err := parseTrashInfo(infoPath)
+	if err != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to read trash info: %v", err)}
+	}
+	info, err := os.Lstat(trashPath)
+	if err != nil {
+		return ReturnValue{Error: fmt.Sprintf("item is no longer in the trash: %v", err)}
+	}
+	if _, err := a.sandboxPath(originalPath, true); err != nil {
+		return errorResult(err)
+	}
+
+	dir := filepath.Dir(originalPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to recreate folder: %v", err)}
+	}
+	target := uniquePath(dir, filepath.Base(originalPath), info.IsDir())
+	if err := movePath(trashPath, target); err != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to restore: %v", err)}
+	}
+	if err := os.Remove(infoPath); err != nil {
+		fmt.Printf("failed to remove trash info: %v\n", err)
+	}
+	if target != originalPath {
+		moveCache(originalPath, target, false)
+	}
+	return ReturnValue{FileExplorer: a.explorerState, Transfers: []PathTransfer{{From: trashPath, To: target}}}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEncodeTrashPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/home/alice/api/login.hurl", "/home/alice/api/login.hurl"},
+		{"/home/alice/my api/login test.hurl", "/home/alice/my%20api/login%20test.hurl"},
+		{"/home/alice/100%/a#b?.hurl", "/home/alice/100%25/a%23b%3F.hurl"},
+		{"/home/alice/café.hurl", "/home/alice/caf%C3%A9.hurl"},
+	}
+	for _, tt := range tests {
+		if got := encodeTrashPath(tt.path); got != tt.want {
+			t.Errorf("encodeTrashPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseTrashInfo(t *testing.T) {
+	deletedAt := time.Date(2024, 3, 1, 14, 30, 5, 0, time.Local)
+	tests := []struct {
+		name     string
+		content  string
+		wantPath string
+		wantDate time.Time
+		wantErr  bool
+	}{
+		{
+			name:     "written by formatTrashInfo",
+			content:  formatTrashInfo("/home/alice/my api/login.hurl", deletedAt),
+			wantPath: "/home/alice/my api/login.hurl",
+			wantDate: deletedAt,
+		},
+		{
+			name:     "other sections and keys are ignored",
+			content:  "[Other]\nPath=/elsewhere\n\n[Trash Info]\nDeletionDate=2024-03-01T14:30:05\nPath=/home/alice/a%20b.hurl\nExtra=1\n",
+			wantPath: "/home/alice/a b.hurl",
+			wantDate: deletedAt,
+		},
+		{
+			name:     "invalid date is left empty",
+			content:  "[Trash Info]\nPath=/home/alice/a.hurl\nDeletionDate=yesterday\n",
+			wantPath: "/home/alice/a.hurl",
+		},
+		{
+			name:    "relative path",
+			content: "[Trash Info]\nPath=a.hurl\nDeletionDate=2024-03-01T14:30:05\n",
+			wantErr: true,
+		},
+		{
+			name:    "path outside the section",
+			content: "Path=/home/alice/a.hurl\n[Trash Info]\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid escape",
+			content: "[Trash Info]\nPath=/home/alice/%zz.hurl\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			infoPath := filepath.Join(t.TempDir(), "item.trashinfo")
+			if err := os.WriteFile(infoPath, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			path, date, err := parseTrashInfo(infoPath)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseTrashInfo = %q, want an error", path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTrashInfo: %v", err)
+			}
+			if path != tt.wantPath || !date.Equal(tt.wantDate) {
+				t.Errorf("parseTrashInfo = %q %v, want %q %v", path, date, tt.wantPath, tt.wantDate)
+			}
+		})
+	}
+}
+
+// newTrashApp returns an app with a workspace open at the returned folder.
+func newTrashApp(t *testing.T) (*App, string) {
+	a := newTestApp(t)
+	ws, err := resolvePath(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.preferences.OpenWorkspaces = []string{ws}
+	a.preferences.ActiveWorkspace = ws
+	a.preferences.Workspaces = []Workspace{{Root: ws}}
+	t.Cleanup(func() { os.RemoveAll(tempOutputPathFor(ws)) })
+	return a, ws
+}
+
+func TestTrashAndRestore(t *testing.T) {
+	a, ws := newTrashApp(t)
+	path := filepath.Join(ws, "login.hurl")
+	cache := tempOutputPathFor(path)
+
+	// Two files trashed from the same path do not collide
+	var trashPaths []string
+	for i := 0; i < 2; i++ {
+		if err := os.WriteFile(path, []byte("GET http://localhost\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.MkdirAll(cache, 0755)
+		result := a.DeletePath(path)
+		if result.Error != "" {
+			t.Fatalf("DeletePath: %s", result.Error)
+		}
+		trashPaths = append(trashPaths, result.Transfers[0].To)
+	}
+	if filepath.Base(trashPaths[0]) != "login.hurl" || filepath.Base(trashPaths[1]) != "login.2.hurl" {
+		t.Errorf("trash paths = %v", trashPaths)
+	}
+	if _, err := os.Stat(cache); err != nil {
+		t.Errorf("cached results removed by a trash: %v", err)
+	}
+
+	// Items deleted outside the workspace are not listed
+	outside := filepath.Join(t.TempDir(), "other.hurl")
+	os.WriteFile(outside, nil, 0644)
+	if _, err := a.trash(outside); err != nil {
+		t.Fatalf("trash: %v", err)
+	}
+	items := a.ListTrash().Trash
+	if len(items) != 2 {
+		t.Fatalf("ListTrash = %+v, want the 2 workspace items", items)
+	}
+	for _, item := range items {
+		if item.OriginalPath != path {
+			t.Errorf("item %s from %s", item.TrashPath, item.OriginalPath)
+		}
+	}
+
+	// Restoring takes the original path, then a " copy" once it is taken
+	for i, want := range []string{path, filepath.Join(ws, "login copy.hurl")} {
+		result := a.RestoreFromTrash(trashPaths[i])
+		if result.Error != "" {
+			t.Fatalf("RestoreFromTrash: %s", result.Error)
+		}
+		if got := result.Transfers[0].To; got != want {
+			t.Errorf("restored to %s, want %s", got, want)
+		}
+	}
+	if items := a.ListTrash().Trash; len(items) != 0 {
+		t.Errorf("ListTrash after restore = %+v", items)
+	}
+}
+
+func TestEmptyTrash(t *testing.T) {
+	a, ws := newTrashApp(t)
+	path := filepath.Join(ws, "login.hurl")
+	cache := tempOutputPathFor(path)
+	os.WriteFile(path, nil, 0644)
+	os.MkdirAll(cache, 0755)
+	if result := a.DeletePath(path); result.Error != "" {
+		t.Fatalf("DeletePath: %s", result.Error)
+	}
+
+	result := a.EmptyTrash()
+	if result.ErrorCode != ErrCodeConfirmationRequired || result.Confirmation.Entries != 1 {
+		t.Fatalf("EmptyTrash = %+v, want a confirmation for 1 item", result)
+	}
+
+	// Only the items listed when asking are deleted
+	later := filepath.Join(ws, "later.hurl")
+	os.WriteFile(later, nil, 0644)
+	if result := a.DeletePath(later); result.Error != "" {
+		t.Fatalf("DeletePath: %s", result.Error)
+	}
+	result = a.ConfirmOperation(result.Confirmation.Token)
+	if result.Error != "" {
+		t.Fatalf("ConfirmOperation: %s", result.Error)
+	}
+	if len(result.Trash) != 1 || result.Trash[0].OriginalPath != later {
+		t.Errorf("trash after EmptyTrash = %+v, want %s only", result.Trash, later)
+	}
+	if _, err := os.Stat(cache); !os.IsNotExist(err) {
+		t.Errorf("cached results kept after EmptyTrash: %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package main
+
+import "errors"
+
+// deviceID is only used by the trash of the desktop, which Windows does not have.
+func deviceID(path string) (uint64, error) {
+	return 0, errors.New("devices are not supported on windows")
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// With HurlOnly, a collapsed folder is searched for a .hurl file this deep
+	// and through this many entries; past that it is assumed to have some.
+	treeProbeDepth   = 4
+	treeProbeEntries = 2000
+	// Statuses of reports cached at most, the cache is reset past that
+	runStatusCacheSize = 10000
+)
+
+// TreeOptions filter the workspace tree. Expanded lists the folders whose
+// children are loaded, the others are returned collapsed and loaded on
+// demand with GetTreeChildren.
+type TreeOptions struct {
+	HurlOnly         bool     `json:"hurlOnly"`
+	ShowHidden       bool     `json:"showHidden"`
+	RespectGitignore bool     `json:"respectGitignore"`
+	Expanded         []string `json:"expanded"`
+}
+
+type TreeNode struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	IsDir    bool   `json:"isDir"`
+	Size     int64  `json:"size"`
+	Modified string `json:"modified"`
+	// HasChildren tells the UI whether a collapsed folder can be expanded.
+	HasChildren bool `json:"hasChildren"`
+	// Children is null for a collapsed folder and a file.
+	Children []TreeNode `json:"children"`
+	// Status of the last run of a .hurl file, empty if it never ran.
+	Status  string `json:"status,omitempty"`
+	LastRun string `json:"lastRun,omitempty"`
+}
+
+type treeWalker struct {
+	options  TreeOptions
+	expanded map[string]bool
+	ignore   *gitignore
+	// probed counts the entries read by hasContent, for treeProbeEntries
+	probed int
+}
+
+func newTreeWalker(root string, options TreeOptions) *treeWalker {
+	w := &treeWalker{options: options, expanded: map[string]bool{}}
+	for _, p := range options.Expanded {
+		w.expanded[filepath.Clean(p)] = true
+	}
+	if options.RespectGitignore {
+		w.ignore = newGitignore(root)
+	}
+	return w
+}
+
+func isHurlFile(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".hurl")
+}
+
+// visible reports whether an entry passes the hidden and .gitignore filters.
+func (w *treeWalker) visible(dir string, entry os.DirEntry) bool {
+	if !w.options.ShowHidden && strings.HasPrefix(entry.Name(), ".") {
+		return false
+	}
+	if w.ignore != nil && w.ignore.ignored(filepath.Join(dir, entry.Name()), entry.IsDir()) {
+		return false
+	}
+	return entry.IsDir() || !w.options.HurlOnly || isHurlFile(entry.Name())
+}
+
+// hasContent reports whether dir has a visible entry. With HurlOnly a folder
+// counts only if it holds a .hurl file. The search stops at the first one, and
+// past treeProbeDepth or treeProbeEntries the folder may have children: it is
+// reported as having some.
+func (w *treeWalker) hasContent(dir string) bool {
+	return w.probe(dir, 0)
+}
+
+func (w *treeWalker) probe(dir string, depth int) bool {
+	if depth > treeProbeDepth || w.probed > treeProbeEntries {
+		return true
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	w.probed += len(entries)
+	var dirs []string
+	for _, entry := range entries {
+		if !w.visible(dir, entry) {
+			continue
+		}
+		if !w.options.HurlOnly || !entry.IsDir() {
+			return true
+		}
+		dirs = append(dirs, filepath.Join(dir, entry.Name()))
+	}
+	// Files of dir first: a .hurl file there saves reading any folder
+	for _, sub := range dirs {
+		if w.probe(sub, depth+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// children lists the visible entries of dir, folders first, in natural order.
+func (w *treeWalker) children(dir string) ([]TreeNode, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []TreeNode{}
+	for _, entry := range entries {
+		if !w.visible(dir, entry) {
+			continue
+		}
+		node, err := w.node(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if node.IsDir && w.options.HurlOnly && !node.HasChildren {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].IsDir != nodes[j].IsDir {
+			return nodes[i].IsDir
+		}
+		return naturalLess(nodes[i].Name, nodes[j].Name)
+	})
+	return nodes, nil
+}
+
+func (w *treeWalker) node(p string) (TreeNode, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return TreeNode{}, err
+	}
+	node := TreeNode{
+		Name:     filepath.Base(p),
+		Path:     p,
+		IsDir:    info.IsDir(),
+		Size:     info.Size(),
+		Modified: info.ModTime().Format("2006-01-02 15:04:05"),
+	}
+	if !node.IsDir {
+		if isHurlFile(node.Name) {
+			node.Status, node.LastRun = lastRunStatus(p)
+		}
+		return node, nil
+	}
+	if w.expanded[p] {
+		children, err := w.children(p)
+		if err != nil {
+			return TreeNode{}, err
+		}
+		node.Children = children
+		node.HasChildren = len(children) > 0
+	} else {
+		node.HasChildren = w.hasContent(p)
+	}
+	return node, nil
+}
+
+type runStatus struct {
+	modTime time.Time
+	size    int64
+	status  string
+}
+
+// runStatuses caches the status of each report, parsed again only when the
+// report changes.
+var runStatuses = struct {
+	sync.Mutex
+	byPath map[string]runStatus
+}{byPath: map[string]runStatus{}}
+
+// lastRunStatus returns the status and time of the cached report of a file.
+func lastRunStatus(filePath string) (string, string) {
+	reportPath := reportPathFor(filePath)
+	info, err := os.Stat(reportPath)
+	if err != nil {
+		return "", ""
+	}
+	lastRun := info.ModTime().Format("2006-01-02 15:04:05")
+
+	runStatuses.Lock()
+	cached, ok := runStatuses.byPath[reportPath]
+	runStatuses.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		if cached.status == "" {
+			return "", ""
+		}
+		return cached.status, lastRun
+	}
+
+	status := parseRunStatus(reportPath)
+	runStatuses.Lock()
+	if len(runStatuses.byPath) >= runStatusCacheSize {
+		runStatuses.byPath = map[string]runStatus{}
+	}
+	runStatuses.byPath[reportPath] = runStatus{modTime: info.ModTime(), size: info.Size(), status: status}
+	runStatuses.Unlock()
+	if status == "" {
+		return "", ""
+	}
+	return status, lastRun
+}
+
+// parseRunStatus returns the status of a report, empty if it has no session.
+func parseRunStatus(reportPath string) string {
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		return ""
+	}
+	var sessions []struct {
+		Success bool `json:"success"`
+	}
+	if err := json.Unmarshal(data, &sessions); err != nil || len(sessions) == 0 {
+		return ""
+	}
+	status := EntryStatusPassed
+	for _, session := range sessions {
+		if !session.Success {
+			status = EntryStatusFailed
+		}
+	}
+	return status
+}
+
+// naturalLess compares names case-insensitively, with runs of digits compared
+// by value so that "file2" comes before "file10".
+func naturalLess(a string, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if unicode.IsDigit(ra[i]) && unicode.IsDigit(rb[j]) {
+			si := i
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			sj := j
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+			na := strings.TrimLeft(string(ra[si:i]), "0")
+			nb := strings.TrimLeft(string(rb[sj:j]), "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			continue
+		}
+		ca, cb := unicode.ToLower(ra[i]), unicode.ToLower(rb[j])
+		if ca != cb {
+			return ca < cb
+		}
+		i++
+		j++
+	}
+	if len(ra)-i != len(rb)-j {
+		return len(ra)-i < len(rb)-j
+	}
+	// Equal ignoring case and leading zeros: keep a stable order
+	return a < b
+}
+
+// GetTree returns the tree of root, the workspace root when empty, with the
+// folders listed in options.Expanded loaded.
+func (a *App) GetTree(root string, options TreeOptions) ReturnValue {
+	if root == "" {
+		root = a.workspaceRoot()
+	}
+	root, err := a.sandboxPath(root, false)
+	if err != nil {
+		return errorResult(err)
+	}
+	options.Expanded = append(options.Expanded, root)
+	walker := newTreeWalker(root, options)
+	node, err := walker.node(root)
+	if err != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to read tree: %v", err)}
+	}
+	if !node.IsDir {
+		return ReturnValue{Error: fmt.Sprintf("path is not a directory: %s", root)}
+	}
+	return ReturnValue{Tree: &node}
+}
+
+// GetTreeChildren loads one collapsed folder of the tree.
+func (a *App) GetTreeChildren(dirPath string, options TreeOptions) ReturnValue {
+	dirPath, err := a.sandboxPath(dirPath, false)
+	if err != nil {
+		return errorResult(err)
+	}
+	root := a.workspaceRoot()
+	if !isWithin(root, dirPath) {
+		root = dirPath
+	}
+	options.Expanded = append(options.Expanded, dirPath)
+	walker := newTreeWalker(root, options)
+	node, err := walker.node(dirPath)
+	if err != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to read folder: %v", err)}
+	}
+	if !node.IsDir {
+		return ReturnValue{Error: fmt.Sprintf("path is not a directory: %s", dirPath)}
+	}
+	return ReturnValue{Tree: &node}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	VariableSourceEnv     = "env"
+	VariableSourceCapture = "capture"
+	VariableSourceOption  = "option"
+	VariableSourceBuiltin = "builtin"
+	// Hurl reads HURL_<name> environment variables as variables
+	VariableSourceOSEnv = "osenv"
+)
+
+var (
+	templateVarRe = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_\-]*)\s*\}\}`)
+	// [Options] variable: name=value
+	optionVariableRe = regexp.MustCompile(`^\s*variable\s*:\s*([A-Za-z_][A-Za-z0-9_\-]*)\s*=`)
+	// variable "name" queries of asserts and captures
+	variableQueryRe = regexp.MustCompile(`\bvariable\s+"([A-Za-z_][A-Za-z0-9_\-]*)"`)
+)
+
+// Template functions provided by hurl itself.
+var builtinVariables = map[string]bool{
+	"newUuid": true,
+	"newDate": true,
+}
+
+// VariableUsage is one {{name}} occurrence in a file. Line and Column are 1-based.
+type VariableUsage struct {
+	Name    string `json:"name"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Defined bool   `json:"defined"`
+	// Source tells where the variable comes from when it is defined.
+	Source string `json:"source,omitempty"`
+}
+
+type VariableAnalysis struct {
+	Usages    []VariableUsage `json:"usages"`
+	Undefined []VariableUsage `json:"undefined"`
+	// Unused lists the env variables that no .hurl file of the workspace uses.
+	Unused []string `json:"unused,omitempty"`
+}
+
+// variableUsages returns every {{name}} of the content, comments excluded.
+func variableUsages(parsed *hurlFile) []VariableUsage {
+	var usages []VariableUsage
+	for i, line := range parsed.Lines {
+		if parsed.Info[i].Comment {
+			continue
+		}
+		for _, m := range templateVarRe.FindAllStringSubmatchIndex(line, -1) {
+			usages = append(usages, VariableUsage{
+				Name:   line[m[2]:m[3]],
+				Line:   i + 1,
+				Column: m[0] + 1,
+			})
+		}
+	}
+	return usages
+}
+
+// analyzeVariables matches the variables used in content against vars and the
+// variables defined earlier in the file by captures or [Options].
+func analyzeVariables(content string, vars map[string]string) VariableAnalysis {
+	parsed := parseHurlFile(content)
+
+	// First line from which a file defined variable is available
+	definedAt := map[string]int{}
+	sources := map[string]string{}
+	define := func(name string, line int, source string) {
+		if first, ok := definedAt[name]; !ok || line < first {
+			definedAt[name] = line
+			sources[name] = source
+		}
+	}
+	for _, e := range parsed.Entries {
+		for _, c := range e.Captures {
+			define(c.Name, c.Line+1, VariableSourceCapture)
+		}
+	}
+	for i, line := range parsed.Lines {
+		if parsed.Info[i].Section != "Options" || parsed.Info[i].Comment {
+			continue
+		}
+		if m := optionVariableRe.FindStringSubmatch(line); m != nil {
+			define(m[1], i+2, VariableSourceOption)
+		}
+	}
+
+	analysis := VariableAnalysis{Usages: []VariableUsage{}, Undefined: []VariableUsage{}}
+	for _, u := range variableUsages(parsed) {
+		switch {
+		case builtinVariables[u.Name]:
+			u.Defined, u.Source = true, VariableSourceBuiltin
+		case hasKey(vars, u.Name):
+			u.Defined, u.Source = true, VariableSourceEnv
+		case hasOSVariable(u.Name):
+			u.Defined, u.Source = true, VariableSourceOSEnv
+		case definedAt[u.Name] > 0 && u.Line >= definedAt[u.Name]:
+			u.Defined, u.Source = true, sources[u.Name]
+		}
+		analysis.Usages = append(analysis.Usages, u)
+		if !u.Defined {
+			analysis.Undefined = append(analysis.Undefined, u)
+		}
+	}
+	return analysis
+}
+
+func hasOSVariable(name string) bool {
+	_, ok := os.LookupEnv("HURL_" + name)
+	return ok
+}
+
+func hasKey(m map[string]string, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+// hurlFilesUnder returns the .hurl files below root, skipping hidden directories.
+func hurlFilesUnder(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable folders should not abort the whole walk
+			if d != nil && d.IsDir() && path != root {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(path), ".hurl") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// unusedVariables returns the names of vars not used by any .hurl file under root.
+func unusedVariables(root string, vars map[string]string) ([]string, error) {
+	files, err := hurlFilesUnder(root)
+	if err != nil {
+		return nil, err
+	}
+
+	used := map[string]bool{}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		for _, u := range variableUsages(parseHurlFile(string(content))) {
+			used[u.Name] = true
+		}
+		for _, m := range variableQueryRe.FindAllStringSubmatch(string(content), -1) {
+			used[m[1]] = true
+		}
+	}
+
+	unused := []string{}
+	for name := range vars {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+	return unused, nil
+}
+
+// AnalyzeVariables lists the variables used in filePath, flags the ones that are
+// not defined by the selected environment or an earlier capture, and lists the
+// env variables that no .hurl file of the active workspace uses. Without an
+// active workspace, no variable is reported unused.
+func (a *App) AnalyzeVariables(filePath string, envName string) ReturnValue {
+	filePath, err := a.sandboxPath(filePath, false)
+	if err != nil {
+		return errorResult(err)
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to read file: %v", err)}
+	}
+
+	vars, err := a.variableNames(filePath, envName)
+	if err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+
+	analysis := analyzeVariables(string(content), vars)
+	// Only a workspace is scanned, the folder browsed could be anything
+	if root := a.indexRoot(); root != "" {
+		analysis.Unused, err = unusedVariables(root, vars)
+		if err != nil {
+			return ReturnValue{Error: fmt.Sprintf("failed to scan workspace: %v", err)}
+		}
+	}
+
+	return ReturnValue{Variables: &analysis}
+}
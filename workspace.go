@@ -0,0 +1,383 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// A workspace is a named root folder. Each one remembers its own last file,
+// folder and environment, its recently opened files and its settings; its
+// project env layers are those of <root>/.hurlstudio. Several workspaces can
+// be open at once, one of them active. The open workspaces are the folders the
+// file bindings may access, see sandbox.go: a new one is only opened through
+// the native folder picker.
+
+const (
+	maxRecentWorkspaces = 10
+	maxRecentFiles      = 50
+)
+
+// WorkspaceSettings override the preferences of the same name while the
+// workspace is active, so trusting one project does not trust the others.
+type WorkspaceSettings struct {
+	AllowShellVariables bool `json:"allowShellVariables"`
+	AllowHooks          bool `json:"allowHooks"`
+}
+
+type Workspace struct {
+	Name           string            `json:"name"`
+	Root           string            `json:"root"`
+	LastOpenedFile string            `json:"lastOpenedFile,omitempty"`
+	LastOpenedDir  string            `json:"lastOpenedDir,omitempty"`
+	LastEnv        string            `json:"lastEnv,omitempty"`
+	RecentFiles    []string          `json:"recentFiles,omitempty"`
+	Settings       WorkspaceSettings `json:"settings"`
+}
+
+type WorkspaceList struct {
+	Active string      `json:"active"`
+	Open   []Workspace `json:"open"`
+	Recent []Workspace `json:"recent"`
+}
+
+// isWithin reports whether path is root or inside it.
+func isWithin(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// pushRecent moves item to the front of list, keeping at most max items.
+func pushRecent(list []string, item string, max int) []string {
+	recent := []string{item}
+	for _, existing := range list {
+		if existing != item && len(recent) < max {
+			recent = append(recent, existing)
+		}
+	}
+	return recent
+}
+
+func removeString(list []string, item string) []string {
+	kept := list[:0:0]
+	for _, existing := range list {
+		if existing != item {
+			kept = append(kept, existing)
+		}
+	}
+	return kept
+}
+
+func (p *Preferences) workspace(root string) *Workspace {
+	for i := range p.Workspaces {
+		if p.Workspaces[i].Root == root {
+			return &p.Workspaces[i]
+		}
+	}
+	return nil
+}
+
+func (p *Preferences) isOpen(root string) bool {
+	for _, open := range p.OpenWorkspaces {
+		if open == root {
+			return true
+		}
+	}
+	return false
+}
+
+// activeWorkspace returns the active workspace, or nil.
+func (a *App) activeWorkspace() *Workspace {
+	if a.preferences.ActiveWorkspace == "" {
+		return nil
+	}
+	return a.preferences.workspace(a.preferences.ActiveWorkspace)
+}
+
+// workspaceRoot returns the folder scanned by workspace-wide features: the
+// root of the active workspace, or the current directory.
+func (a *App) workspaceRoot() string {
+	if ws := a.activeWorkspace(); ws != nil {
+		return ws.Root
+	}
+	return a.explorerState.CurrentDir.Path
+}
+
+// indexRoot returns the folder the file index covers: the root of the active
+// workspace only, a folder the user opened on purpose, and none otherwise.
+func (a *App) indexRoot() string {
+	if ws := a.activeWorkspace(); ws != nil {
+		return ws.Root
+	}
+	return ""
+}
+
+func (a *App) allowHooks() bool {
+	if ws := a.activeWorkspace(); ws != nil {
+		return ws.Settings.AllowHooks
+	}
+	return a.preferences.AllowHooks
+}
+
+// recordOpenedFile remembers the selected file in the active workspace, or
+// in the recent files of the preferences outside workspaces.
+func (a *App) recordOpenedFile(path string) {
+	if path == "" {
+		return
+	}
+	ws := a.activeWorkspace()
+	if ws == nil {
+		a.preferences.RecentFiles = pushRecent(a.preferences.RecentFiles, path, maxRecentFiles)
+		return
+	}
+	if isWithin(ws.Root, path) {
+		ws.LastOpenedFile = path
+		ws.RecentFiles = pushRecent(ws.RecentFiles, path, maxRecentFiles)
+	}
+}
+
+// recordOpenedDir remembers the browsed directory in the active workspace.
+func (a *App) recordOpenedDir(path string) {
+	if ws := a.activeWorkspace(); ws != nil && isWithin(ws.Root, path) {
+		ws.LastOpenedDir = path
+	}
+}
+
+// recordEnv remembers the environment last used in the active workspace.
+func (a *App) recordEnv(envName string) {
+	ws := a.activeWorkspace()
+	if ws == nil || ws.LastEnv == envName {
+		return
+	}
+	ws.LastEnv = envName
+	if err := a.savePreferences(); err != nil {
+		fmt.Printf("failed to save preferences: %v\n", err)
+	}
+}
+
+// restoreWorkspace shows the last folder and file of ws, falling back to its root.
+func (a *App) restoreWorkspace(ws *Workspace) {
+	dir := ws.Root
+	if ws.LastOpenedDir != "" && isWithin(ws.Root, ws.LastOpenedDir) {
+		if stat, err := os.Stat(ws.LastOpenedDir); err == nil && stat.IsDir() {
+			dir = ws.LastOpenedDir
+		}
+	}
+	if dirInfo, err := createFileInfo(dir); err == nil {
+		a.explorerState.CurrentDir = dirInfo
+	}
+
+	a.explorerState.SelectedFile = FileInfo{}
+	if ws.LastOpenedFile != "" {
+		if fi, err := createFileInfo(ws.LastOpenedFile); err == nil && !fi.IsDir {
+			a.setCurrentFile(fi)
+		}
+	}
+	a.syncWatchers()
+}
+
+// seedWorkspace opens the folder browsed before workspaces existed as the
+// first workspace, so the files the user worked on stay reachable after an
+// upgrade. Folders checkWorkspaceRoot refuses, the home folder the explorer
+// starts in included, are skipped: the user picks a workspace instead.
+func (a *App) seedWorkspace() {
+	if len(a.preferences.Workspaces) > 0 {
+		return
+	}
+	candidates := []string{a.preferences.LastOpenedDir}
+	if a.preferences.LastOpenedFile != "" {
+		candidates = append(candidates, filepath.Dir(a.preferences.LastOpenedFile))
+	}
+	for _, root := range candidates {
+		if root == "" || !filepath.IsAbs(root) || checkWorkspaceRoot(root) != nil {
+			continue
+		}
+		if stat, err := os.Stat(root); err != nil || !stat.IsDir() {
+			continue
+		}
+		ws := Workspace{Name: filepath.Base(root), Root: root, LastOpenedDir: root}
+		if isWithin(root, a.preferences.LastOpenedFile) {
+			ws.LastOpenedFile = a.preferences.LastOpenedFile
+			ws.RecentFiles = []string{ws.LastOpenedFile}
+		}
+		a.preferences.Workspaces = []Workspace{ws}
+		a.preferences.OpenWorkspaces = []string{root}
+		a.preferences.RecentWorkspaces = []string{root}
+		a.preferences.ActiveWorkspace = root
+		if err := a.savePreferences(); err != nil {
+			fmt.Printf("failed to save preferences: %v\n", err)
+		}
+		return
+	}
+}
+
+func (a *App) workspaceList() *WorkspaceList {
+	list := &WorkspaceList{Active: a.preferences.ActiveWorkspace, Open: []Workspace{}, Recent: []Workspace{}}
+	for _, root := range a.preferences.OpenWorkspaces {
+		if ws := a.preferences.workspace(root); ws != nil {
+			list.Open = append(list.Open, *ws)
+		}
+	}
+	for _, root := range a.preferences.RecentWorkspaces {
+		if ws := a.preferences.workspace(root); ws != nil {
+			list.Recent = append(list.Recent, *ws)
+		}
+	}
+	return list
+}
+
+func (a *App) workspaceResult() ReturnValue {
+	if err := a.savePreferences(); err != nil {
+		return ReturnValue{Error: err.Error()}
+	}
+	return ReturnValue{FileExplorer: a.explorerState, Workspaces: a.workspaceList(), Workspace: a.activeWorkspace()}
+}
+
+// GetWorkspaces returns the open workspaces, the active one and the most
+// recently used ones.
+func (a *App) GetWorkspaces() ReturnValue {
+	return ReturnValue{Workspaces: a.workspaceList(), Workspace: a.activeWorkspace()}
+}
+
+// OpenWorkspace asks for a folder with the native folder picker, opens it as
+// a workspace and switches to it. An empty name keeps the current name, or
+// uses the folder name for a new workspace. Canceling the picker changes
+// nothing.
+func (a *App) OpenWorkspace(name string) ReturnValue {
+	root, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:                "Open workspace",
+		CanCreateDirectories: true,
+	})
+	if err != nil {
+		return ReturnValue{Error: fmt.Sprintf("failed to open folder picker: %v", err)}
+	}
+	if root == "" {
+		return a.GetWorkspaces()
+	}
+	return a.openWorkspace(root, name)
+}
+
+// ReopenWorkspace opens a workspace of the recent list again. Only folders
+// once picked with OpenWorkspace are known.
+func (a *App) ReopenWorkspace(root string) ReturnValue {
+	if a.preferences.workspace(root) == nil {
+		return ReturnValue{Error: fmt.Sprintf("unknown workspace: %s", root)}
+	}
+	return a.openWorkspace(root, "")
+}
+
+// checkWorkspaceRoot refuses a file system root and the home folder, which
+// would open every file of the user to the file bindings.
+func checkWorkspaceRoot(root string) error {
+	resolved, err := resolvePath(root)
+	if err != nil {
+		return fmt.Errorf("invalid workspace path: %w", err)
+	}
+	if filepath.Dir(resolved) == resolved {
+		return fmt.Errorf("cannot open the root of the file system as a workspace: %s", root)
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		if home, err := resolvePath(homeDir); err == nil && resolved == home {
+			return fmt.Errorf("cannot open the home folder as a workspace, pick a project folder: %s", root)
+		}
+	}
+	return nil
+}
+
+func (a *App) openWorkspace(root string, name string) ReturnValue {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return ReturnValue{Error: fmt.Sprintf("invalid workspace path: %v", err)}
+	}
+	if err := checkWorkspaceRoot(root); err != nil {
+		return ReturnValue{Error: err.Error(), ErrorCode: ErrCodeProtectedPath}
+	}
+	stat, err := os.Stat(root)
+	if err != nil {
+		return ReturnValue{Error: fmt.Sprintf("workspace does not exist: %v", err)}
+	}
+	if !stat.IsDir() {
+		return ReturnValue{Error: fmt.Sprintf("workspace is not a directory: %s", root)}
+	}
+
+	ws := a.preferences.workspace(root)
+	if ws == nil {
+		a.preferences.Workspaces = append(a.preferences.Workspaces, Workspace{Name: filepath.Base(root), Root: root})
+		ws = a.preferences.workspace(root)
+	}
+	if name = strings.TrimSpace(name); name != "" {
+		ws.Name = name
+	}
+	if !a.preferences.isOpen(root) {
+		a.preferences.OpenWorkspaces = append(a.preferences.OpenWorkspaces, root)
+	}
+	return a.SwitchWorkspace(root)
+}
+
+// SwitchWorkspace makes an open workspace active and restores its last
+// folder, file and environment.
+func (a *App) SwitchWorkspace(root string) ReturnValue {
+	if !a.preferences.isOpen(root) {
+		return ReturnValue{Error: fmt.Sprintf("workspace is not open: %s", root)}
+	}
+	ws := a.preferences.workspace(root)
+	if _, err := os.Stat(ws.Root); err != nil {
+		return ReturnValue{Error: fmt.Sprintf("workspace does not exist: %v", err)}
+	}
+	a.preferences.ActiveWorkspace = root
+	a.preferences.RecentWorkspaces = pushRecent(a.preferences.RecentWorkspaces, root, maxRecentWorkspaces)
+	a.restoreWorkspace(ws)
+	return a.workspaceResult()
+}
+
+// CloseWorkspace closes an open workspace. Closing the active one switches to
+// the most recently used workspace still open, if any. The workspace stays in
+// the recent list.
+func (a *App) CloseWorkspace(root string) ReturnValue {
+	if !a.preferences.isOpen(root) {
+		return ReturnValue{Error: fmt.Sprintf("workspace is not open: %s", root)}
+	}
+	a.preferences.OpenWorkspaces = removeString(a.preferences.OpenWorkspaces, root)
+	if a.preferences.ActiveWorkspace != root {
+		return a.workspaceResult()
+	}
+
+	a.preferences.ActiveWorkspace = ""
+	for _, recent := range a.preferences.RecentWorkspaces {
+		if a.preferences.isOpen(recent) {
+			if result := a.SwitchWorkspace(recent); result.Error == "" {
+				return result
+			}
+		}
+	}
+	return a.workspaceResult()
+}
+
+// ForgetWorkspace removes a closed workspace and its state from the recent list.
+func (a *App) ForgetWorkspace(root string) ReturnValue {
+	if a.preferences.isOpen(root) {
+		return ReturnValue{Error: fmt.Sprintf("close the workspace before forgetting it: %s", root)}
+	}
+	a.preferences.RecentWorkspaces = removeString(a.preferences.RecentWorkspaces, root)
+	kept := a.preferences.Workspaces[:0]
+	for _, ws := range a.preferences.Workspaces {
+		if ws.Root != root {
+			kept = append(kept, ws)
+		}
+	}
+	a.preferences.Workspaces = kept
+	return a.workspaceResult()
+}
+
+// SetCurrentEnv records the environment selected in the active workspace so
+// that switching back to it restores the environment.
+func (a *App) SetCurrentEnv(envName string) ReturnValue {
+	a.recordEnv(envName)
+	return ReturnValue{}
+}